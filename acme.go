@@ -0,0 +1,29 @@
+package zerocert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+// newLegoConfig returns a lego configuration for user on the CA at directoryURL,
+// trusting roots instead of the system roots if not nil. An empty
+// directoryURL selects the Let's Encrypt production directory.
+func newLegoConfig(user registration.User, directoryURL string, roots *x509.CertPool) *lego.Config {
+	config := lego.NewConfig(user)
+	if directoryURL != "" {
+		config.CADirURL = directoryURL
+	}
+	if roots != nil {
+		if t, ok := config.HTTPClient.Transport.(*http.Transport); ok {
+			if t.TLSClientConfig == nil {
+				t.TLSClientConfig = &tls.Config{}
+			}
+			t.TLSClientConfig.RootCAs = roots
+		}
+	}
+	return config
+}
@@ -0,0 +1,438 @@
+package zerocert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testACMEServer is a minimal ACME CA issuing certificates without any
+// challenge. Requests are not authenticated. Orders with the shortlived
+// profile get certificates valid for 6 days.
+type testACMEServer struct {
+	*httptest.Server
+	roots *x509.CertPool
+
+	// ari enables the renewal information of the issued certificates.
+	ari bool
+	// retryAfter, if not zero, is sent along with the renewal information.
+	retryAfter time.Duration
+	// failOrders rejects every order.
+	failOrders bool
+	// maxOrders, if not zero, rejects the orders beyond it.
+	maxOrders int
+	// maxRevocations, if not zero, rejects the revocations beyond it.
+	maxRevocations int
+	// ocsp enables an OCSP responder for the issued certificates, served over
+	// plain HTTP like the ones of public CAs.
+	ocsp bool
+
+	ocspResponder *httptest.Server
+
+	caKey *ecdsa.PrivateKey
+	ca    *x509.Certificate
+
+	mu     sync.Mutex
+	certs  [][]byte
+	orders int
+	// profiles holds the profile of each order.
+	profiles []string
+	revoked  int
+	// issued holds the ARI identifiers of the issued certificates.
+	issued map[string]bool
+	// renewalInfos is the number of renewal information requests.
+	renewalInfos int
+	// accounts holds the payloads of the account registrations.
+	accounts []map[string]json.RawMessage
+	// ocspRequests is the number of OCSP requests.
+	ocspRequests int
+	// ocspRevoked holds the serial numbers of the certificates the OCSP
+	// responder reports as revoked.
+	ocspRevoked map[string]bool
+}
+
+// newTestACMEServer starts a test ACME server with the default options.
+func newTestACMEServer(t *testing.T) *testACMEServer {
+	return (&testACMEServer{}).start(t)
+}
+
+// start starts s with the options set in its fields.
+func (s *testACMEServer) start(t *testing.T) *testACMEServer {
+	t.Helper()
+	var err error
+	if s.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, s.caKey.Public(), s.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	s.issued = map[string]bool{}
+
+	mux := http.NewServeMux()
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	mux.HandleFunc("/directory", s.serveDirectory)
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", rand.Text())
+	})
+	mux.HandleFunc("POST /account", s.serveAccount)
+	mux.HandleFunc("POST /order", s.serveOrder)
+	mux.HandleFunc("POST /finalize/{id}", s.serveFinalize)
+	mux.HandleFunc("POST /cert/{id}", s.serveCert)
+	mux.HandleFunc("GET /renewal-info/{id}", s.serveRenewalInfo)
+	mux.HandleFunc("POST /revoke", s.serveRevoke)
+	if s.ocsp {
+		s.ocspResponder = httptest.NewServer(http.HandlerFunc(s.serveOCSP))
+		t.Cleanup(s.ocspResponder.Close)
+	}
+	s.roots = x509.NewCertPool()
+	s.roots.AddCert(s.Certificate())
+	return s
+}
+
+// directory returns the URL of the ACME directory.
+func (s *testACMEServer) directory() string {
+	return s.URL + "/directory"
+}
+
+// stats returns the number of orders and revocations received.
+func (s *testACMEServer) stats() (orders, revoked int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.orders, s.revoked
+}
+
+// registrations returns the payloads of the account registrations received.
+func (s *testACMEServer) registrations() []map[string]json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.accounts)
+}
+
+// ocspStats returns the number of OCSP requests received.
+func (s *testACMEServer) ocspStats() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ocspRequests
+}
+
+// revokeOCSP makes the OCSP responder report cert as revoked.
+func (s *testACMEServer) revokeOCSP(cert *x509.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ocspRevoked == nil {
+		s.ocspRevoked = map[string]bool{}
+	}
+	s.ocspRevoked[cert.SerialNumber.String()] = true
+}
+
+// setFailOrders sets failOrders.
+func (s *testACMEServer) setFailOrders(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failOrders = fail
+}
+
+// renewalInfoRequests returns the number of renewal information requests
+// received.
+func (s *testACMEServer) renewalInfoRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.renewalInfos
+}
+
+func (s *testACMEServer) serveDirectory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var ari string
+	if s.ari {
+		ari = fmt.Sprintf(`,"renewalInfo":"%s/renewal-info"`, s.URL)
+	}
+	fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order","revokeCert":"%[1]s/revoke","keyChange":"%[1]s/key-change"%[2]s}`, s.URL, ari)
+}
+
+func (s *testACMEServer) serveAccount(w http.ResponseWriter, r *http.Request) {
+	var payload map[string]json.RawMessage
+	if err := readJWSPayload(r, &payload); err != nil {
+		writeACMEResponse(w, http.StatusBadRequest, map[string]string{
+			"type":   "urn:ietf:params:acme:error:malformed",
+			"detail": err.Error(),
+		})
+		return
+	}
+	s.mu.Lock()
+	s.accounts = append(s.accounts, payload)
+	id := len(s.accounts)
+	s.mu.Unlock()
+	w.Header().Set("Location", fmt.Sprintf("%s/account/%d", s.URL, id))
+	writeACMEResponse(w, http.StatusCreated, map[string]any{
+		"status":  "valid",
+		"contact": payload["contact"],
+	})
+}
+
+func (s *testACMEServer) serveOrder(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	rejected := s.failOrders || (s.maxOrders > 0 && s.orders >= s.maxOrders)
+	if rejected {
+		s.orders++
+	}
+	s.mu.Unlock()
+	if rejected {
+		writeACMEResponse(w, http.StatusForbidden, map[string]string{
+			"type":   "urn:ietf:params:acme:error:unauthorized",
+			"detail": "orders are disabled",
+		})
+		return
+	}
+	var order map[string]any
+	if err := readJWSPayload(r, &order); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	profile, _ := order["profile"].(string)
+	s.mu.Lock()
+	s.orders++
+	s.profiles = append(s.profiles, profile)
+	id := len(s.profiles) - 1
+	s.mu.Unlock()
+	order["status"] = "ready"
+	order["authorizations"] = []string{}
+	order["finalize"] = fmt.Sprintf("%s/finalize/%d", s.URL, id)
+	w.Header().Set("Location", fmt.Sprintf("%s/order/%d", s.URL, id))
+	writeACMEResponse(w, http.StatusCreated, order)
+}
+
+func (s *testACMEServer) serveFinalize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CSR string `json:"csr"`
+	}
+	if err := readJWSPayload(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := base64.RawURLEncoding.DecodeString(req.CSR)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var order int
+	fmt.Sscan(r.PathValue("id"), &order)
+	s.mu.Lock()
+	shortLived := order >= 0 && order < len(s.profiles) && s.profiles[order] == "shortlived"
+	s.mu.Unlock()
+	lifetime := 90 * 24 * time.Hour
+	if shortLived {
+		lifetime = 6 * 24 * time.Hour
+	}
+	var ocspServer []string
+	if s.ocspResponder != nil {
+		ocspServer = []string{s.ocspResponder.URL}
+	}
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	notBefore := time.Now().Add(-time.Minute)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: serial,
+		OCSPServer:   ocspServer,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, s.ca, csr.PublicKey, s.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	certID, err := certificate.MakeARICertID(leaf)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.certs = append(s.certs, der)
+	s.issued[certID] = true
+	id := len(s.certs) - 1
+	s.mu.Unlock()
+	writeACMEResponse(w, http.StatusOK, map[string]any{
+		"status":      "valid",
+		"certificate": fmt.Sprintf("%s/cert/%d", s.URL, id),
+	})
+}
+
+func (s *testACMEServer) serveCert(w http.ResponseWriter, r *http.Request) {
+	var id int
+	fmt.Sscan(r.PathValue("id"), &id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 0 || id >= len(s.certs) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Header().Set("Replay-Nonce", rand.Text())
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.certs[id]})
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Raw})
+}
+
+func (s *testACMEServer) serveRenewalInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	issued := s.issued[r.PathValue("id")]
+	s.renewalInfos++
+	s.mu.Unlock()
+	if !issued {
+		writeACMEResponse(w, http.StatusNotFound, map[string]string{
+			"type":   "urn:ietf:params:acme:error:malformed",
+			"detail": "unknown certificate",
+		})
+		return
+	}
+	start := time.Now().Add(24 * time.Hour)
+	if s.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.retryAfter.Seconds())))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"suggestedWindow":{"start":"%s","end":"%s"}}`,
+		start.Format(time.RFC3339), start.Add(time.Hour).Format(time.RFC3339))
+}
+
+// serveOCSP answers that every certificate is good for 4 days, unless revoked
+// with revokeOCSP.
+func (s *testACMEServer) serveOCSP(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := ocsp.ParseRequest(b)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.ocspRequests++
+	status := ocsp.Good
+	if s.ocspRevoked[req.SerialNumber.String()] {
+		status = ocsp.Revoked
+	}
+	s.mu.Unlock()
+	now := time.Now().Truncate(time.Second)
+	resp, err := ocsp.CreateResponse(s.ca, s.ca, ocsp.Response{
+		Status:       status,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(4 * 24 * time.Hour),
+		RevokedAt:    now,
+	}, s.caKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+func (s *testACMEServer) serveRevoke(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxRevocations > 0 && s.revoked >= s.maxRevocations {
+		writeACMEResponse(w, http.StatusForbidden, map[string]string{
+			"type":   "urn:ietf:params:acme:error:unauthorized",
+			"detail": "too many revocations",
+		})
+		return
+	}
+	s.revoked++
+	w.Header().Set("Replay-Nonce", rand.Text())
+}
+
+// eabKeyID returns the key ID of the HS256 external account binding of the
+// registration payload.
+func eabKeyID(t *testing.T, payload map[string]json.RawMessage) string {
+	t.Helper()
+	var eab struct {
+		Protected string `json:"protected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(payload["externalAccountBinding"], &eab); err != nil {
+		t.Fatalf("registration without externalAccountBinding: %v", err)
+	}
+	protected, err := base64.RawURLEncoding.DecodeString(eab.Protected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(protected, &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Alg != "HS256" || eab.Signature == "" {
+		t.Errorf("externalAccountBinding not signed with HS256: %+v", header)
+	}
+	return header.Kid
+}
+
+// readJWSPayload decodes the payload of the JWS request r into v.
+func readJWSPayload(r *http.Request, v any) error {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeACMEResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Replay-Nonce", rand.Text())
+	if status >= 400 {
+		w.Header().Set("Content-Type", "application/problem+json")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,290 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	legodns01 "github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+// CA is an ACME certificate authority along with the account used with it.
+type CA struct {
+	// DirectoryURL is the URL of the ACME directory of the CA. It defaults to
+	// the Let's Encrypt production directory.
+	DirectoryURL string
+
+	// CARoots is the set of root certificates trusted to connect to the CA. It
+	// defaults to the system roots.
+	CARoots *x509.CertPool
+
+	// Email is the ACME account's email address.
+	Email string
+
+	// Reg is the ACME account's registration URI.
+	Reg string
+
+	// Key is the ACME account's private key.
+	Key []byte
+
+	// EAB is the external account binding required by some CAs, and
+	// TermsOfServiceAgreed the agreement to their terms of service needed to
+	// register with it. See Config.EAB.
+	EAB                  *ExternalAccountBinding
+	TermsOfServiceAgreed bool
+
+	// PreferredChain and Profile select the chain and ACME profile of the
+	// certificates issued by the CA. See Config.PreferredChain and
+	// Config.Profile.
+	PreferredChain string
+	Profile        string
+}
+
+// directory returns the directory URL of the CA.
+func (ca CA) directory() string {
+	if ca.DirectoryURL == "" {
+		return lego.LEDirectoryProduction
+	}
+	return ca.DirectoryURL
+}
+
+// acmeCA holds the ACME client of a CA, created on first use so an
+// unreachable fallback CA does not prevent the Manager from starting.
+type acmeCA struct {
+	CA
+	mu     sync.Mutex
+	client *lego.Client
+}
+
+// cas returns the CAs of the configuration in order of preference.
+func (c Config) cas() []CA {
+	primary := CA{
+		DirectoryURL:         c.DirectoryURL,
+		CARoots:              c.CARoots,
+		Email:                c.Email,
+		Reg:                  c.Reg,
+		Key:                  c.Key,
+		EAB:                  c.EAB,
+		TermsOfServiceAgreed: c.TermsOfServiceAgreed,
+		PreferredChain:       c.PreferredChain,
+		Profile:              c.Profile,
+	}
+	return append([]CA{primary}, c.FallbackCAs...)
+}
+
+// acmeClient returns the ACME client of the i-th CA, creating it if needed.
+func (m *Manager) acmeClient(i int) (*lego.Client, error) {
+	ca := m.acmeCAs[i]
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.client != nil {
+		return ca.client, nil
+	}
+
+	account := &Account{Email: ca.Email, Reg: ca.Reg, Key: ca.Key}
+	config := newLegoConfig(account, ca.DirectoryURL, ca.CARoots)
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("create ACME client: %v", err)
+	}
+	switch m.challenge {
+	case ChallengeTLSALPN01:
+		err = client.Challenge.SetTLSALPN01Provider(&m.tlsalpn01Provider)
+	case ChallengeHTTP01:
+		err = client.Challenge.SetHTTP01Provider(&m.http01Provider)
+	default:
+		var opts []legodns01.ChallengeOption
+		if m.builtinDNS() {
+			opts = append(opts, legodns01.WrapPreCheck(m.preCheckDNS01))
+		}
+		err = client.Challenge.SetDNS01Provider(m.dnsChallengeProvider(), opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("set %s provider: %v", m.challenge, err)
+	}
+	ca.client = client
+	return client, nil
+}
+
+// accountClient returns the ACME client of the i-th CA like acmeClient, once
+// its account is registered. Accounts with an external account binding and no
+// Reg are registered on first use, so only the CAs actually ordering or
+// revoking certificates get one. The registration is kept next to the cache
+// file to be reused after a restart.
+func (m *Manager) accountClient(i int) (*lego.Client, error) {
+	client, err := m.acmeClient(i)
+	if err != nil {
+		return nil, err
+	}
+	ca := m.acmeCAs[i]
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if ca.Reg != "" || ca.EAB == nil {
+		return client, nil
+	}
+	reg, err := client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+		TermsOfServiceAgreed: ca.TermsOfServiceAgreed,
+		Kid:                  ca.EAB.KeyID,
+		HmacEncoded:          ca.EAB.HMACKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("register with EAB: %v", err)
+	}
+	ca.Reg = reg.URI
+	m.logger.Info("registered ACME account", slog.String("ca", ca.directory()), slog.String("reg", reg.URI))
+	if err := m.saveAccount(ca.CA); err != nil {
+		m.logger.Warn("saving ACME account failed", slog.String("ca", ca.directory()), slog.Any("error", err))
+	}
+	return client, nil
+}
+
+// accountsFile returns the file storing the accounts registered with an
+// external account binding, next to the cache file, or an empty string
+// without cache file.
+func (m *Manager) accountsFile() string {
+	if m.CacheFile == "" {
+		return ""
+	}
+	return m.CacheFile + ".accounts"
+}
+
+// accountID identifies the account of ca by its directory and key, so a
+// registration is not reused with another key.
+func accountID(ca CA) string {
+	sum := sha256.Sum256(ca.Key)
+	return ca.directory() + " " + hex.EncodeToString(sum[:])
+}
+
+// loadAccounts sets the Reg of the CAs with an external account binding and
+// no Reg to the registration saved before a restart, if any.
+func (m *Manager) loadAccounts() error {
+	file := m.accountsFile()
+	if file == "" {
+		return nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	m.accountsMu.Lock()
+	defer m.accountsMu.Unlock()
+	m.accounts = make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		// Each line holds the directory, the key hash and the registration
+		// URI of an account.
+		if f := strings.Fields(line); len(f) == 3 {
+			m.accounts[f[0]+" "+f[1]] = f[2]
+		}
+	}
+	for _, ca := range m.acmeCAs {
+		if ca.Reg == "" && ca.EAB != nil {
+			ca.Reg = m.accounts[accountID(ca.CA)]
+		}
+	}
+	return nil
+}
+
+// saveAccount adds the registration of ca to the accounts file.
+func (m *Manager) saveAccount(ca CA) error {
+	file := m.accountsFile()
+	if file == "" {
+		return nil
+	}
+	m.accountsMu.Lock()
+	defer m.accountsMu.Unlock()
+	if m.accounts == nil {
+		m.accounts = make(map[string]string)
+	}
+	m.accounts[accountID(ca)] = ca.Reg
+	lines := make([]string, 0, len(m.accounts))
+	for id, reg := range m.accounts {
+		lines = append(lines, id+" "+reg+"\n")
+	}
+	slices.Sort(lines)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(strings.Join(lines, "")), 0600)
+}
+
+// Issuer returns the directory URL of the CA that issued the served
+// certificate, or an empty string if unknown. The issuer of a certificate
+// loaded from a cache or received from another member of the cluster is
+// found with the next renewal check.
+func (m *Manager) Issuer() string {
+	m.certMu.RLock()
+	defer m.certMu.RUnlock()
+	if m.issuer < 0 {
+		return ""
+	}
+	return m.acmeCAs[m.issuer].directory()
+}
+
+// resolveIssuer finds the CA that issued the served certificates when it is not
+// known, e.g. after a restart or when they were obtained by another member.
+// With a single CA, it is the issuer. Otherwise, only the issuing CA provides
+// renewal information for a certificate, so the CAs supporting ARI are asked
+// in order. Their answers are kept like the renewal windows, so the CAs are
+// not asked again before their next fetch. CAs without ARI can't be told
+// apart, and certificates of different key types issued by different CAs
+// have no single issuer, so both leave the issuer unknown.
+func (m *Manager) resolveIssuer(ctx context.Context) {
+	m.certMu.RLock()
+	certs, issuer := m.certs, m.issuer
+	m.certMu.RUnlock()
+	if issuer >= 0 || len(certs) == 0 {
+		return
+	}
+
+	if len(m.acmeCAs) == 1 {
+		issuer = 0
+	} else {
+		var leaves []*x509.Certificate
+		for _, cert := range certs {
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return
+			}
+			leaves = append(leaves, leaf)
+		}
+		for i := range m.acmeCAs {
+			if m.knowsAll(ctx, i, leaves) {
+				issuer = i
+				break
+			}
+		}
+	}
+	if issuer < 0 {
+		return
+	}
+
+	m.certMu.Lock()
+	defer m.certMu.Unlock()
+	if len(m.certs) > 0 && m.certs[0] == certs[0] {
+		m.issuer = issuer
+	}
+}
+
+// knowsAll returns true if the i-th CA provides the renewal window of every
+// leaf, i.e. if it issued all of them.
+func (m *Manager) knowsAll(ctx context.Context, i int, leaves []*x509.Certificate) bool {
+	for _, leaf := range leaves {
+		if w, _ := m.renewalInfo(ctx, i, leaf); w.renewAt.IsZero() {
+			return false
+		}
+	}
+	return true
+}
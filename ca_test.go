@@ -0,0 +1,228 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_failover(t *testing.T) {
+	primary := (&testACMEServer{ari: true, failOrders: true}).start(t)
+	fallback := (&testACMEServer{ari: true}).start(t)
+	cfg := Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: primary.directory(),
+		CARoots:      primary.roots,
+		FallbackCAs: []CA{{
+			DirectoryURL: fallback.directory(),
+			CARoots:      fallback.roots,
+			Key:          []byte(testKey),
+		}},
+	}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.obtain(context.Background()); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	if got := m.Issuer(); got != fallback.directory() {
+		t.Errorf("Issuer() = %q, want %q", got, fallback.directory())
+	}
+	if orders, _ := primary.stats(); orders != 1 {
+		t.Errorf("primary CA got %d orders, want 1", orders)
+	}
+	if orders, _ := fallback.stats(); orders != 1 {
+		t.Errorf("fallback CA got %d orders, want 1", orders)
+	}
+
+	// A restarted member loading the certificate from the cache finds its
+	// issuer again.
+	restarted, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	restarted.setCertificates(m.getCertificates(), SourceCache, -1)
+	if got := restarted.Issuer(); got != "" {
+		t.Errorf("Issuer() before check = %q, want empty", got)
+	}
+	if restarted.needsRefresh(context.Background()) {
+		t.Error("needsRefresh() = true, want false")
+	}
+	if got := restarted.Issuer(); got != fallback.directory() {
+		t.Errorf("Issuer() after check = %q, want %q", got, fallback.directory())
+	}
+}
+
+func TestManager_accountClient_EAB(t *testing.T) {
+	primary := (&testACMEServer{ari: true}).start(t)
+	fallback := (&testACMEServer{ari: true}).start(t)
+	hmacKey := base64.RawURLEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	cfg := Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: primary.directory(),
+		CARoots:      primary.roots,
+		EAB:          &ExternalAccountBinding{KeyID: "kid-1", HMACKey: hmacKey},
+		CacheFile:    filepath.Join(t.TempDir(), "cert.pem"),
+		FallbackCAs: []CA{{
+			DirectoryURL: fallback.directory(),
+			CARoots:      fallback.roots,
+			Key:          []byte(testKey),
+			EAB:          &ExternalAccountBinding{KeyID: "kid-2", HMACKey: hmacKey},
+		}},
+	}
+	// The terms of service must be agreed to explicitly, for every CA.
+	if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), "requires TermsOfServiceAgreed") {
+		t.Fatalf("New() without TermsOfServiceAgreed error = %v", err)
+	}
+	cfg.TermsOfServiceAgreed = true
+	if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), fallback.directory()) {
+		t.Fatalf("New() without TermsOfServiceAgreed for the fallback CA error = %v", err)
+	}
+	cfg.FallbackCAs[0].TermsOfServiceAgreed = true
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+
+	// Looking for the issuer of a certificate registers no account.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.setCertificates([]*tls.Certificate{testKeyPair(t, key, time.Now().Add(-time.Hour), 90*24*time.Hour)}, SourceCache, -1)
+	m.resolveIssuer(ctx)
+	if n := len(primary.registrations()) + len(fallback.registrations()); n != 0 {
+		t.Fatalf("CAs got %d registrations at startup, want 0", n)
+	}
+
+	// The account is registered with its binding before the first order,
+	// and only once.
+	for range 2 {
+		if err := m.obtain(ctx); err != nil {
+			t.Fatalf("obtain() error = %v", err)
+		}
+	}
+	regs := primary.registrations()
+	if len(regs) != 1 {
+		t.Fatalf("primary CA got %d registrations, want 1", len(regs))
+	}
+	if kid := eabKeyID(t, regs[0]); kid != "kid-1" {
+		t.Errorf("externalAccountBinding key ID = %q, want kid-1", kid)
+	}
+	if tos := string(regs[0]["termsOfServiceAgreed"]); tos != "true" {
+		t.Errorf("termsOfServiceAgreed = %s, want true", tos)
+	}
+	if n := len(fallback.registrations()); n != 0 {
+		t.Errorf("fallback CA got %d registrations, want 0", n)
+	}
+
+	// A restarted member reuses the saved registration.
+	restarted, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Close()
+	if err := restarted.obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	if n := len(primary.registrations()); n != 1 {
+		t.Errorf("primary CA got %d registrations after restart, want 1", n)
+	}
+}
+
+func TestManager_failover_partial(t *testing.T) {
+	// The primary CA issues the certificate of the first key type only.
+	primary := (&testACMEServer{ari: true, maxOrders: 1}).start(t)
+	fallback := (&testACMEServer{ari: true}).start(t)
+	m, err := New(Config{
+		Key:             []byte(testKey),
+		Domain:          "example.com",
+		KeyType:         EC256,
+		FallbackKeyType: RSA2048,
+		DirectoryURL:    primary.directory(),
+		CARoots:         primary.roots,
+		FallbackCAs: []CA{{
+			DirectoryURL: fallback.directory(),
+			CARoots:      fallback.roots,
+			Key:          []byte(testKey),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+	if err := m.obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	// Only the missing key type is ordered from the fallback CA.
+	if orders, _ := primary.stats(); orders != 2 {
+		t.Errorf("primary CA got %d orders, want 2", orders)
+	}
+	if orders, _ := fallback.stats(); orders != 1 {
+		t.Errorf("fallback CA got %d orders, want 1", orders)
+	}
+	certs := m.getCertificates()
+	if len(certs) != 2 {
+		t.Fatalf("got %d certificates, want 2", len(certs))
+	}
+	for i, ca := range []*testACMEServer{primary, fallback} {
+		leaf, err := x509.ParseCertificate(certs[i].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := leaf.CheckSignatureFrom(ca.ca); err != nil {
+			t.Errorf("certificate %d not issued by CA %d: %v", i, i, err)
+		}
+	}
+
+	// The certificates have no single issuer.
+	if m.needsRefresh(ctx) {
+		t.Error("needsRefresh() = true, want false")
+	}
+	if got := m.Issuer(); got != "" {
+		t.Errorf("Issuer() = %q, want empty", got)
+	}
+}
+
+func TestManager_failover_badKey(t *testing.T) {
+	primary := (&testACMEServer{ari: true, failOrders: true}).start(t)
+	fallback := (&testACMEServer{ari: true}).start(t)
+	for _, key := range [][]byte{nil, []byte("invalid")} {
+		_, err := New(Config{
+			Key:          []byte(testKey),
+			Domain:       "example.com",
+			DirectoryURL: primary.directory(),
+			CARoots:      primary.roots,
+			FallbackCAs: []CA{{
+				DirectoryURL: fallback.directory(),
+				CARoots:      fallback.roots,
+				Reg:          "y",
+				Key:          key,
+			}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "loading ACME key of "+fallback.directory()) {
+			t.Errorf("New() with fallback key %q error = %v, want loading ACME key error", key, err)
+		}
+	}
+
+	if key := (&Account{Key: []byte("invalid")}).GetPrivateKey(); key != nil {
+		t.Errorf("GetPrivateKey() = %#v, want nil", key)
+	}
+}
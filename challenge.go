@@ -0,0 +1,133 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	legodns01 "github.com/go-acme/lego/v4/challenge/dns01"
+	legotlsalpn01 "github.com/go-acme/lego/v4/challenge/tlsalpn01"
+
+	"github.com/rs/zerocert/internal/dns01"
+)
+
+// ChallengeType is the type of ACME challenge used to prove the control of the
+// names of the certificate.
+type ChallengeType string
+
+const (
+	// ChallengeDNS01 answers DNS-01 challenges with the DNS listener. It is the
+	// only challenge type supporting wildcard names.
+	ChallengeDNS01 ChallengeType = "dns-01"
+
+	// ChallengeTLSALPN01 answers TLS-ALPN-01 challenges with the TLS listener,
+	// which must be reachable on port 443 of every name of the certificate.
+	ChallengeTLSALPN01 ChallengeType = "tls-alpn-01"
+
+	// ChallengeHTTP01 answers HTTP-01 challenges with the handler returned by
+	// Manager.HTTPHandler, which must be reachable on port 80 of every name of
+	// the certificate.
+	ChallengeHTTP01 ChallengeType = "http-01"
+)
+
+func (c ChallengeType) valid() bool {
+	switch c {
+	case ChallengeDNS01, ChallengeTLSALPN01, ChallengeHTTP01:
+		return true
+	}
+	return false
+}
+
+// preCheckDNS01 makes sure every member of the cluster answers the DNS-01
+// challenge before the CA is asked to validate it, so a member with a broken
+// DNS listener does not make the validation fail. lego retries the check until
+// its propagation timeout and then fails with the misbehaving members.
+func (m *Manager) preCheckDNS01(domain, fqdn, value string, check legodns01.PreCheckFunc) (bool, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+	checker := dns01.SelfChecker{GetIPs: m.glueClient.RetreiveIPs}
+	if err := checker.Check(ctx, fqdn, value); err != nil {
+		m.logger.Warn("dns-01: self-check failed", slog.String("fqdn", fqdn), slog.Any("error", err))
+		return false, err
+	}
+	return check(fqdn, value)
+}
+
+// challengeKey returns the key identifying a pending challenge between the
+// members of the cluster.
+func challengeKey(typ ChallengeType, id string) string {
+	return string(typ) + " " + id
+}
+
+// localChallenge returns the value of the pending challenge identified by key
+// if presented by this member.
+func (m *Manager) localChallenge(key string) (string, bool) {
+	typ, id, _ := strings.Cut(key, " ")
+	switch ChallengeType(typ) {
+	case ChallengeTLSALPN01:
+		return m.tlsalpn01Provider.KeyAuth(id)
+	case ChallengeHTTP01:
+		return m.http01Provider.KeyAuth(id)
+	}
+	return "", false
+}
+
+// challengeTimeout bounds the time spent asking the other members of the
+// cluster for a challenge, as any client can trigger it.
+const challengeTimeout = 5 * time.Second
+
+// challengeValue returns the value of the pending challenge identified by key,
+// presented either by this member or by another member of the cluster as the
+// CA can validate the challenge against any of them. The other members are
+// only known once the TLS listener is created, which may come after the
+// HTTP-01 handler.
+func (m *Manager) challengeValue(ctx context.Context, key string) (string, error) {
+	if value, found := m.localChallenge(key); found {
+		return value, nil
+	}
+	select {
+	case <-m.tlsListenerStarted:
+	default:
+		return "", fmt.Errorf("challenge not found: %s", key)
+	}
+	ctx, cancel := context.WithTimeout(ctx, challengeTimeout)
+	defer cancel()
+	return m.peers.Challenge(ctx, key)
+}
+
+// isName returns true if host is one of the names of the certificate. Only
+// those names have challenges to look for in the cluster.
+func (m *Manager) isName(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	return slices.ContainsFunc(m.names, func(name string) bool {
+		return strings.EqualFold(name, host)
+	})
+}
+
+// tlsALPN01Config returns the TLS config answering the TLS-ALPN-01 validation
+// connection described by chi with the challenge certificate of its server
+// name.
+func (m *Manager) tlsALPN01Config(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+	if !m.isName(chi.ServerName) {
+		m.logger.Debug("tls-alpn-01: not a name of the certificate", slog.String("domain", chi.ServerName))
+		return nil, fmt.Errorf("no challenge for %q", chi.ServerName)
+	}
+	keyAuth, err := m.challengeValue(chi.Context(), challengeKey(ChallengeTLSALPN01, chi.ServerName))
+	if err != nil {
+		m.logger.Warn("tls-alpn-01: unknown challenge", slog.String("domain", chi.ServerName), slog.Any("error", err))
+		return nil, err
+	}
+	cert, err := legotlsalpn01.ChallengeCert(chi.ServerName, keyAuth)
+	if err != nil {
+		return nil, err
+	}
+	m.logger.Debug("tls-alpn-01: answering challenge", slog.String("domain", chi.ServerName))
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   []string{legotlsalpn01.ACMETLS1Protocol},
+	}, nil
+}
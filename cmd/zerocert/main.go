@@ -0,0 +1,146 @@
+// Command zerocert provides tooling around the zerocert package.
+//
+// Usage:
+//
+//	zerocert register [-email address] [-directory url] [-ca-roots file]
+//	                  [-accept-tos] [-eab-kid id -eab-hmac key]
+//	                  [-format text|json]
+//
+// The register subcommand creates and registers a new ACME account, and
+// prints the Email, Reg and Key values to set in zerocert.Config. CAs that
+// require an external account binding need the -eab-kid and -eab-hmac
+// credentials provided by the CA. The -ca-roots PEM file holds the root
+// certificates of a private ACME server, like zerocert.Config.CARoots.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/rs/zerocert"
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "register":
+		err = register(ctx, args, os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "zerocert: unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "zerocert: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: zerocert register [-email address] [-directory url] [-ca-roots file] [-accept-tos] [-eab-kid id -eab-hmac key] [-format text|json]\n")
+}
+
+func register(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("register", flag.ExitOnError)
+	email := fs.String("email", "", "Email address of the account, prompted if empty")
+	directory := fs.String("directory", "", "ACME directory URL, defaults to Let's Encrypt production")
+	caRoots := fs.String("ca-roots", "", "PEM file of the root certificates trusted to connect to the CA, defaults to the system roots")
+	acceptTOS := fs.Bool("accept-tos", false, "Accept the terms of service of the CA")
+	eabKID := fs.String("eab-kid", "", "External account binding key ID provided by the CA")
+	eabHMAC := fs.String("eab-hmac", "", "External account binding base64url MAC key provided by the CA")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+	if (*eabKID == "") != (*eabHMAC == "") {
+		return errors.New("-eab-kid and -eab-hmac must be set together")
+	}
+	if !*acceptTOS {
+		return errors.New("the terms of service of the CA must be accepted with -accept-tos")
+	}
+	var roots *x509.CertPool
+	if *caRoots != "" {
+		var err error
+		if roots, err = loadRoots(*caRoots); err != nil {
+			return err
+		}
+	}
+	if *email == "" {
+		var err error
+		if *email, err = prompt(os.Stdin, os.Stderr, "Email: "); err != nil {
+			return err
+		}
+	}
+
+	opts := []zerocert.RegisterOption{zerocert.WithCARoots(roots)}
+	if *eabKID != "" {
+		opts = append(opts, zerocert.WithEAB(zerocert.ExternalAccountBinding{
+			KeyID:   *eabKID,
+			HMACKey: *eabHMAC,
+		}))
+	}
+	account, err := zerocert.Register(ctx, *email, *directory, *acceptTOS, opts...)
+	if err != nil {
+		return err
+	}
+	return printAccount(stdout, account, *format)
+}
+
+// loadRoots returns a pool of the certificates of the PEM file at path.
+func loadRoots(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("%s: no PEM certificate found", path)
+	}
+	return roots, nil
+}
+
+func prompt(r io.Reader, w io.Writer, label string) (string, error) {
+	fmt.Fprint(w, label)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func printAccount(w io.Writer, account *zerocert.Account, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Email string `json:"email"`
+			Reg   string `json:"reg"`
+			Key   string `json:"key"`
+		}{account.Email, account.Reg, string(account.Key)})
+	default:
+		_, err := fmt.Fprintf(w, "Email: %s\nReg: %s\nKey:\n%s", account.Email, account.Reg, account.Key)
+		return err
+	}
+}
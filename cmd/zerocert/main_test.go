@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestACMEServer starts an ACME server accepting every account
+// registration and returns it with the path of its PEM root certificate.
+func newTestACMEServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	s := httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	mux.HandleFunc("/directory", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order","revokeCert":"%[1]s/revoke","keyChange":"%[1]s/key-change"}`, s.URL)
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+	})
+	mux.HandleFunc("POST /account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		w.Header().Set("Location", s.URL+"/account/1")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"valid"}`)
+	})
+
+	roots := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(roots, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return s, roots
+}
+
+func TestRegister(t *testing.T) {
+	acme, roots := newTestACMEServer(t)
+	var out bytes.Buffer
+	err := register(context.Background(), []string{
+		"-email", "test@example.com",
+		"-directory", acme.URL + "/directory",
+		"-ca-roots", roots,
+		"-accept-tos",
+		"-format", "json",
+	}, &out)
+	if err != nil {
+		t.Fatalf("register() error = %v", err)
+	}
+
+	var account struct {
+		Email string `json:"email"`
+		Reg   string `json:"reg"`
+		Key   string `json:"key"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &account); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if account.Email != "test@example.com" {
+		t.Errorf("email = %q, want test@example.com", account.Email)
+	}
+	if want := acme.URL + "/account/1"; account.Reg != want {
+		t.Errorf("reg = %q, want %q", account.Reg, want)
+	}
+	if block, _ := pem.Decode([]byte(account.Key)); block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Errorf("key = %q, want an EC private key", account.Key)
+	}
+}
+
+func TestRegister_invalidFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"format", []string{"-accept-tos", "-format", "yaml"}, "unsupported format"},
+		{"partial EAB", []string{"-accept-tos", "-eab-kid", "kid"}, "must be set together"},
+		{"terms of service", []string{"-email", "test@example.com"}, "-accept-tos"},
+		{"roots", []string{"-accept-tos", "-ca-roots", filepath.Join(t.TempDir(), "missing.pem")}, "missing.pem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := register(context.Background(), tt.args, &out)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("register() error = %v, want %q", err, tt.wantErr)
+			}
+			if out.Len() > 0 {
+				t.Errorf("register() printed %q", out.String())
+			}
+		})
+	}
+}
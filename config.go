@@ -0,0 +1,156 @@
+package zerocert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/go-acme/lego/v4/challenge"
+)
+
+// Config holds the configuration of a Manager.
+type Config struct {
+	// Email is the ACME account's email address.
+	Email string
+
+	// Reg is the ACME account's registration URI.
+	Reg string
+
+	// Key is the ACME account's private key.
+	Key []byte
+
+	// DirectoryURL is the URL of the ACME directory of the CA, e.g. a staging
+	// or private ACME server. It defaults to the Let's Encrypt production
+	// directory.
+	DirectoryURL string
+
+	// CARoots is the set of root certificates trusted by the ACME HTTP client.
+	// It defaults to the system roots. Set it to use a private ACME server
+	// with its own CA.
+	CARoots *x509.CertPool
+
+	// EAB is the external account binding required by some CAs. If set and
+	// Reg is empty, the account is registered with the binding, or resolved if
+	// it already exists, before the first order. The registration is saved
+	// next to the CacheFile to be reused after a restart. Registering requires
+	// TermsOfServiceAgreed.
+	EAB *ExternalAccountBinding
+
+	// TermsOfServiceAgreed tells the CA that its terms of service have been
+	// agreed to when registering the account with EAB.
+	TermsOfServiceAgreed bool
+
+	// PreferredChain is the common name of the root or intermediate of the
+	// alternate chain to use if the CA offers one, e.g. "ISRG Root X1". The
+	// default chain of the CA is used otherwise.
+	PreferredChain string
+
+	// Profile is the ACME certificate profile to request from CAs supporting
+	// them, e.g. "tlsserver" or "shortlived". The renewal of short lived
+	// certificates is scheduled from their actual lifetime, see
+	// RenewalPolicy.
+	Profile string
+
+	// FallbackCAs lists CAs tried in order when obtaining a certificate from
+	// the CA defined by DirectoryURL, Email, Reg, Key and EAB fails, each with
+	// its own account. Key is still used to secure the communication between
+	// the members of the cluster.
+	FallbackCAs []CA
+
+	// Domain is the domain to obtain a certificate for. It is a shorthand for
+	// a single entry in Domains.
+	Domain string
+
+	// Domains lists additional zones delegated to the cluster. The DNS-01
+	// challenges of each zone are served by the hosts listed in the glue
+	// records of that zone.
+	Domains []string
+
+	// Names lists the names to include in the certificate, the first one being
+	// used as the common name. It defaults to the wildcard and apex names of
+	// each zone, or to the apex names only if Challenge does not support
+	// wildcards.
+	Names []string
+
+	// Challenge is the type of ACME challenge answered by the cluster. It
+	// defaults to ChallengeDNS01, which requires the zones to be delegated to
+	// the cluster. Other challenge types are answered by the member the CA
+	// connects to, whichever member presented it.
+	Challenge ChallengeType
+
+	// DNSProvider, if set, presents the DNS-01 challenges in place of the
+	// built-in DNS server, e.g. with any of the lego DNS providers. The zones
+	// don't need to be delegated to the cluster and NewDNSListener is not
+	// needed, the cluster only shares the certificates. PeerIPs must be set
+	// for the members of a cluster to find each other.
+	DNSProvider challenge.Provider
+
+	// PeerIPs lists the IP addresses of the members of the cluster. It
+	// defaults to the glue records of the zones with the built-in DNS server.
+	// Without it, when the zones are not delegated to the cluster, each member
+	// works on its own.
+	PeerIPs []net.IP
+
+	// KeyType is the type of key used for the certificate. It defaults to
+	// RSA2048.
+	KeyType KeyType
+
+	// FallbackKeyType is the key type of a second certificate obtained along
+	// with the KeyType one. It is served to clients that do not support the
+	// first certificate, typically with KeyType EC256 and FallbackKeyType
+	// RSA2048.
+	FallbackKeyType KeyType
+
+	// CacheFile is the file to store the certificate and key. The
+	// fingerprints of the revoked certificates are stored next to it, in the
+	// same file name with the .revoked suffix, and the accounts registered
+	// with an external account binding with the .accounts suffix.
+	CacheFile string
+
+	// TLSConfig serves as a base configuration for the TLS server.
+	TLSConfig *tls.Config
+
+	// RenewalPolicy decides when certificates must be renewed if the CA does
+	// not suggest a renewal window. It defaults to renewing certificates after
+	// two thirds of their lifetime.
+	RenewalPolicy RenewalPolicy
+
+	// Logger receives the logs of the Manager and its listeners. It defaults
+	// to slog.Default().
+	Logger *slog.Logger
+
+	// Metrics receives measurements about renewals, peers, DNS-01 queries and
+	// handshakes. See PrometheusMetrics for a ready to use implementation.
+	Metrics Metrics
+
+	// OnCertificateObtained is called when a new certificate is obtained from
+	// the CA by this host. Like the other hooks, it is called synchronously
+	// and must return quickly.
+	OnCertificateObtained func(CertificateEvent)
+
+	// OnCertificateLoadedFromPeer is called when a certificate different from
+	// the served one is fetched from a member of the cluster.
+	OnCertificateLoadedFromPeer func(CertificateEvent)
+
+	// OnRenewalFailed is called when obtaining a new certificate from the CA
+	// fails.
+	OnRenewalFailed func(CertificateEvent)
+
+	// OnExpiringSoon is called when the served certificate is due for renewal
+	// and no fresher certificate is available from the caches. It is called by
+	// every member of the cluster, before the one holding the issuance lease
+	// obtains a new certificate, and only once per certificate even if the
+	// renewal is retried.
+	OnExpiringSoon func(CertificateEvent)
+
+	// CheckInterval is the interval between two renewal checks performed by
+	// Run. It defaults to 24 hours.
+	CheckInterval time.Duration
+
+	// SyncInterval is the interval at which Run compares the served
+	// certificate with the ones of the other members of the cluster, and
+	// fetches a newer one if a member has it. It defaults to one hour.
+	SyncInterval time.Duration
+}
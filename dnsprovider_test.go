@@ -0,0 +1,130 @@
+package zerocert
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	legodns01 "github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/miekg/dns"
+)
+
+func TestManager_DNSProvider(t *testing.T) {
+	ns := newTestUpdateServer(t, "example.com.")
+	config := rfc2136.NewDefaultConfig()
+	config.Nameserver = ns.addr
+	provider, err := rfc2136.NewDNSProviderConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acme := newTestACMEServer(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DNSProvider:  provider,
+		DirectoryURL: acme.URL + "/directory",
+		CARoots:      acme.roots,
+		CacheFile:    filepath.Join(t.TempDir(), "cert.pem"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	fqdn, value := legodns01.GetRecord("example.com", "keyAuth")
+	if err := m.dnsChallengeProvider().Present("example.com", "token", "keyAuth"); err != nil {
+		t.Fatalf("Present() error = %v", err)
+	}
+	if got := ns.txt(fqdn); !slices.Equal(got, []string{value}) {
+		t.Errorf("TXT records of %s after Present = %v, want [%s]", fqdn, got, value)
+	}
+	if err := m.dnsChallengeProvider().CleanUp("example.com", "token", "keyAuth"); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if got := ns.txt(fqdn); len(got) != 0 {
+		t.Errorf("TXT records of %s after CleanUp = %v, want none", fqdn, got)
+	}
+
+	// LoadOrRefresh must not wait for a DNS listener.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NewTLSListener(l); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.LoadOrRefresh(ctx); err != nil {
+		t.Errorf("LoadOrRefresh() error = %v", err)
+	}
+	if m.GetCertificate() == nil {
+		t.Error("no certificate obtained")
+	}
+}
+
+type testUpdateServer struct {
+	addr    string
+	mu      sync.Mutex
+	records map[string][]string
+}
+
+// newTestUpdateServer starts a DNS server authoritative for zone that accepts
+// RFC 2136 additions and deletions of TXT records.
+func newTestUpdateServer(t *testing.T, zone string) *testUpdateServer {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testUpdateServer{addr: pc.LocalAddr().String(), records: map[string][]string{}}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		res := new(dns.Msg)
+		res.SetReply(r)
+		res.Authoritative = true
+		switch r.Opcode {
+		case dns.OpcodeUpdate:
+			s.mu.Lock()
+			for _, rr := range r.Ns {
+				txt, ok := rr.(*dns.TXT)
+				if !ok {
+					continue
+				}
+				switch rr.Header().Class {
+				case dns.ClassINET:
+					s.records[txt.Hdr.Name] = append(s.records[txt.Hdr.Name], txt.Txt...)
+				case dns.ClassNONE:
+					s.records[txt.Hdr.Name] = slices.DeleteFunc(s.records[txt.Hdr.Name], func(v string) bool {
+						return slices.Contains(txt.Txt, v)
+					})
+				}
+			}
+			s.mu.Unlock()
+		default:
+			soa, _ := dns.NewRR(zone + " 60 IN SOA ns." + zone + " admin." + zone + " 1 60 60 60 60")
+			if len(r.Question) > 0 && r.Question[0].Name == zone {
+				res.Answer = append(res.Answer, soa)
+			} else {
+				res.Ns = append(res.Ns, soa)
+			}
+		}
+		w.WriteMsg(res)
+	})}
+	// Updates are rejected by default.
+	srv.MsgAcceptFunc = func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept }
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	return s
+}
+
+func (s *testUpdateServer) txt(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[name]
+}
@@ -0,0 +1,52 @@
+package zerocert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// CertificateSource tells where a certificate comes from.
+type CertificateSource string
+
+const (
+	// SourceACME is a certificate obtained from the CA by this host.
+	SourceACME CertificateSource = "acme"
+	// SourcePeer is a certificate fetched from a member of the cluster.
+	SourcePeer CertificateSource = "peer"
+	// SourceCache is a certificate loaded from the local cache file.
+	SourceCache CertificateSource = "cache"
+)
+
+// CertificateEvent describes a change in the lifecycle of the certificate.
+// Old and New are the leaves of the certificate of the configured KeyType
+// before and after the change, and are nil when not applicable. Issuer is the
+// directory URL of the CA that issued New, if known.
+type CertificateEvent struct {
+	Old    *x509.Certificate
+	New    *x509.Certificate
+	Source CertificateSource
+	Issuer string
+	Err    error
+}
+
+// leaf returns the parsed leaf of the first certificate in certs or nil.
+func leaf(certs []*tls.Certificate) *x509.Certificate {
+	if len(certs) == 0 {
+		return nil
+	}
+	if certs[0].Leaf != nil {
+		return certs[0].Leaf
+	}
+	x509Cert, err := x509.ParseCertificate(certs[0].Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return x509Cert
+}
+
+// emit calls hook with e if hook is set. Hooks are called synchronously.
+func emit(hook func(CertificateEvent), e CertificateEvent) {
+	if hook != nil {
+		hook(e)
+	}
+}
@@ -0,0 +1,162 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder counts the events emitted by the hooks of a Manager.
+type hookRecorder struct {
+	mu     sync.Mutex
+	counts map[string]int
+	issuer string
+}
+
+// record sets the hooks of m to count their events in r.
+func (r *hookRecorder) record(m *Manager) {
+	r.counts = map[string]int{}
+	hook := func(name string) func(CertificateEvent) {
+		return func(e CertificateEvent) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.counts[name]++
+			if e.Issuer != "" {
+				r.issuer = e.Issuer
+			}
+		}
+	}
+	m.OnCertificateObtained = hook("obtained")
+	m.OnCertificateLoadedFromPeer = hook("peer")
+	m.OnExpiringSoon = hook("expiring")
+	m.OnRenewalFailed = hook("failed")
+}
+
+// check reports an error if the counted events differ from want.
+func (r *hookRecorder) check(t *testing.T, member int, want map[string]int) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !maps.Equal(r.counts, want) {
+		t.Errorf("member %d events = %v, want %v", member, r.counts, want)
+	}
+}
+
+func TestManager_markExpiring(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var leaves []*x509.Certificate
+	for range 2 {
+		cert := testKeyPair(t, key, time.Now().Add(-time.Hour), 90*24*time.Hour)
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaves = append(leaves, x509Cert)
+	}
+
+	// OnExpiringSoon fires once per certificate, not on every renewal attempt.
+	m := &Manager{}
+	for i, want := range []bool{true, false, false} {
+		if got := m.markExpiring(leaves[0]); got != want {
+			t.Errorf("markExpiring() call %d = %v, want %v", i+1, got, want)
+		}
+	}
+	if !m.markExpiring(leaves[1]) {
+		t.Error("markExpiring() = false for a new certificate, want true")
+	}
+}
+
+func TestManager_hooks(t *testing.T) {
+	acme := newTestACMEServer(t)
+	members := newTestCluster(t, 3, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	recorders := make([]hookRecorder, len(members))
+	for i, m := range members {
+		recorders[i].record(m)
+	}
+	ctx := context.Background()
+
+	// Obtained from the CA.
+	if err := members[0].obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	// Loaded from a peer.
+	if err := members[1].LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	// Pushed by a peer. The member already serving it is not notified again.
+	members[0].pushCertificates(ctx)
+
+	recorders[0].check(t, 0, map[string]int{"obtained": 1})
+	recorders[1].check(t, 1, map[string]int{"peer": 1})
+	recorders[2].check(t, 2, map[string]int{"peer": 1})
+	if recorders[0].issuer != acme.directory() {
+		t.Errorf("obtained event issuer = %q, want %q", recorders[0].issuer, acme.directory())
+	}
+}
+
+func TestManager_OnExpiringSoon(t *testing.T) {
+	acme := newTestACMEServer(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := 24 * time.Hour
+	expiring := []*tls.Certificate{testKeyPair(t, key, time.Now().Add(-80*day), 90*day)}
+	recorders := make([]hookRecorder, len(members))
+	ctx := context.Background()
+	for i, m := range members {
+		m.setCertificates(expiring, SourceCache, -1)
+		if err := m.saveCache(ctx); err != nil {
+			t.Fatal(err)
+		}
+		recorders[i].record(m)
+	}
+
+	// The member waiting for the lease reports the expiring certificate too.
+	if granted, err := members[0].acquireLease(ctx); err != nil || !granted {
+		t.Fatalf("acquireLease() = %v, %v, want true", granted, err)
+	}
+	// It is reported once, even if the member tries again.
+	for range 2 {
+		waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		err := members[1].LoadOrRefresh(waitCtx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("LoadOrRefresh() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	}
+	members[0].releaseLease(ctx)
+	if err := members[0].LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	// The renewed certificate is then loaded from the peer.
+	if err := members[1].LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+
+	recorders[0].check(t, 0, map[string]int{"expiring": 1, "obtained": 1})
+	recorders[1].check(t, 1, map[string]int{"expiring": 1, "peer": 1})
+}
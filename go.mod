@@ -5,13 +5,13 @@ go 1.24.0
 require (
 	github.com/go-acme/lego/v4 v4.22.2
 	github.com/miekg/dns v1.1.63
+	golang.org/x/crypto v0.36.0
 	golang.org/x/net v0.37.0
 )
 
 require (
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
 	github.com/go-jose/go-jose/v4 v4.0.5 // indirect
-	golang.org/x/crypto v0.36.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
 	golang.org/x/sync v0.12.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
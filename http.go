@@ -0,0 +1,65 @@
+package zerocert
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	legohttp01 "github.com/go-acme/lego/v4/challenge/http01"
+)
+
+// HTTPHandler returns a handler answering the HTTP-01 validation requests of
+// the CA, with the challenges presented by this member or, if unknown, by
+// another member of the cluster. Validation requests for other hosts than the
+// names of the certificate are rejected without asking the cluster. Other
+// requests, and every request if the challenge type is not ChallengeHTTP01,
+// are passed to fallback. If fallback is nil, GET and HEAD requests are
+// redirected to HTTPS and other requests are rejected.
+//
+// The handler must be served on port 80 of every name of the certificate with
+// the ChallengeHTTP01 challenge.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if fallback == nil {
+		fallback = http.HandlerFunc(redirectHTTPS)
+	}
+	prefix := legohttp01.ChallengePath("")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if m.challenge != ChallengeHTTP01 || !ok || token == "" || strings.Contains(token, "/") {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !m.isName(host) {
+			// Only the names of the certificate have challenges to look for
+			// in the cluster.
+			http.NotFound(w, r)
+			return
+		}
+		keyAuth, err := m.challengeValue(r.Context(), challengeKey(ChallengeHTTP01, token))
+		if err != nil {
+			m.logger.Warn("http-01: unknown challenge", slog.String("domain", r.Host), slog.Any("error", err))
+			http.NotFound(w, r)
+			return
+		}
+		m.logger.Debug("http-01: answering challenge", slog.String("domain", r.Host))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(keyAuth))
+	})
+}
+
+func redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Use HTTPS", http.StatusBadRequest)
+		return
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+}
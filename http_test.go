@@ -0,0 +1,128 @@
+package zerocert
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestManager_HTTPHandler(t *testing.T) {
+	acme := newTestACMEServer(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		Challenge:    ChallengeHTTP01,
+		DirectoryURL: acme.URL + "/directory",
+		CARoots:      acme.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.http01Provider.Present("example.com", "Token_1", "keyAuth")
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"known token", "http://example.com/.well-known/acme-challenge/Token_1", http.StatusOK, "keyAuth"},
+		{"unknown token", "http://example.com/.well-known/acme-challenge/token_1", http.StatusNotFound, ""},
+		{"with port", "http://example.com:80/.well-known/acme-challenge/Token_1", http.StatusOK, "keyAuth"},
+		{"other name", "http://other.com/.well-known/acme-challenge/Token_1", http.StatusNotFound, ""},
+		{"other path", "http://example.com/index.html", http.StatusFound, ""},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NewTLSListener(l); err != nil {
+		t.Fatal(err)
+	}
+	var lookups int
+	m.peers.GetIPs = func(context.Context) ([]net.IP, error) {
+		lookups++
+		return nil, nil
+	}
+	h := m.HTTPHandler(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+	// Only the unknown token of a name of the certificate is looked up in the
+	// cluster.
+	if lookups != 1 {
+		t.Errorf("peers looked up %d times, want 1", lookups)
+	}
+}
+
+func TestManager_HTTPHandler_otherChallenge(t *testing.T) {
+	acme := newTestACMEServer(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		Challenge:    ChallengeTLSALPN01,
+		DirectoryURL: acme.URL + "/directory",
+		CARoots:      acme.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.http01Provider.Present("example.com", "Token_1", "keyAuth")
+
+	// Challenge requests are not answered, nor looked up in the cluster.
+	w := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/Token_1", nil))
+	if w.Code != http.StatusFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusFound)
+	}
+}
+
+func TestManager_HTTPHandler_beforeTLSListener(t *testing.T) {
+	acme := newTestACMEServer(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		Challenge:    ChallengeHTTP01,
+		DirectoryURL: acme.URL + "/directory",
+		CARoots:      acme.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	// Port 80 is usually served before the TLS listener is created.
+	h := m.HTTPHandler(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 10 {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/token", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+		}
+	}()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.NewTLSListener(l); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/tls"
 )
 
+// Cache stores a set of key pairs for the same names, one per key type, the
+// first one being the preferred one.
 type Cache interface {
-	Get(ctx context.Context) (*tls.Certificate, error)
-	Put(ctx context.Context, cert *tls.Certificate) error
+	Get(ctx context.Context) ([]*tls.Certificate, error)
+	Put(ctx context.Context, certs []*tls.Certificate) error
 }
@@ -12,7 +12,7 @@ import (
 // File is a cache that stores certificates in a file.
 type File string
 
-func (c File) Get(ctx context.Context) (*tls.Certificate, error) {
+func (c File) Get(ctx context.Context) ([]*tls.Certificate, error) {
 	b, err := os.ReadFile(string(c))
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -20,11 +20,11 @@ func (c File) Get(ctx context.Context) (*tls.Certificate, error) {
 		}
 		return nil, err
 	}
-	return tlsutil.ParseKeyPair(b, nil)
+	return tlsutil.ParseKeyPairs(b, nil)
 }
 
-func (c File) Put(ctx context.Context, cert *tls.Certificate) error {
-	pem, err := tlsutil.EncodeKeyPair(cert)
+func (c File) Put(ctx context.Context, certs []*tls.Certificate) error {
+	pem, err := tlsutil.EncodeKeyPairs(certs)
 	if err != nil {
 		return err
 	}
@@ -33,3 +33,11 @@ func (c File) Put(ctx context.Context, cert *tls.Certificate) error {
 	}
 	return os.WriteFile(string(c), pem, 0600)
 }
+
+// Delete removes the file, if it exists.
+func (c File) Delete(ctx context.Context) error {
+	if err := os.Remove(string(c)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -4,43 +4,73 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/rs/zerocert/internal/tlsutil"
 )
 
 // Layered is a cache that handle certificates in multiple caches.
-type Layered []Cache
+type Layered struct {
+	Caches []Cache
+
+	// Logger receives the non-fatal errors of the caches. It defaults to
+	// slog.Default().
+	Logger *slog.Logger
+}
 
 // Get returns the most recent certificate returned by the layered caches.
-func (c Layered) Get(ctx context.Context) (*tls.Certificate, error) {
-	var certs []*tls.Certificate
+func (c Layered) Get(ctx context.Context) ([]*tls.Certificate, error) {
+	certs, _, err := c.GetFrom(ctx)
+	return certs, err
+}
+
+// GetFrom is like Get but also returns the cache the certificate comes from.
+func (c Layered) GetFrom(ctx context.Context) ([]*tls.Certificate, Cache, error) {
+	var sets [][]*tls.Certificate
+	var from []Cache
 	var errs []error
-	for _, cache := range c {
-		crt, err := cache.Get(ctx)
-		if crt != nil {
-			certs = append(certs, crt)
+	for _, cache := range c.Caches {
+		certs, err := cache.Get(ctx)
+		if len(certs) > 0 {
+			sets = append(sets, certs)
+			from = append(from, cache)
 		}
 		if err != nil {
 			errs = append(errs, err)
 		}
 	}
-	if len(certs) == 0 && len(errs) > 0 {
-		return nil, errors.Join(errs...)
+	if len(sets) == 0 && len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	if len(errs) > 0 {
+		c.logger().Warn("cache fetch non-fatal error", slog.Any("error", errors.Join(errs...)))
 	}
-	if len(certs) == 0 {
-		log.Printf("cache fetch non-fatal error: %v", errs)
+	latest, err := tlsutil.LatestKeyPairs(sets)
+	if err != nil {
+		return nil, nil, err
 	}
-	return tlsutil.LatestCertificate(certs)
+	for i, certs := range sets {
+		if len(latest) > 0 && certs[0] == latest[0] {
+			return latest, from[i], nil
+		}
+	}
+	return latest, nil, nil
 }
 
 // Put stores the certificate in all caches.
-func (c Layered) Put(ctx context.Context, cert *tls.Certificate) error {
+func (c Layered) Put(ctx context.Context, certs []*tls.Certificate) error {
 	var errs []error
-	for _, cache := range c {
-		if err := cache.Put(ctx, cert); err != nil {
+	for _, cache := range c.Caches {
+		if err := cache.Put(ctx, certs); err != nil {
 			errs = append(errs, err)
 		}
 	}
 	return errors.Join(errs...)
 }
+
+func (c Layered) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,80 @@
+package dns01
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SelfChecker queries every server of a cluster directly to make sure they all
+// answer a DNS-01 challenge before the CA is asked to validate it.
+type SelfChecker struct {
+	GetIPs func(context.Context, string) ([]net.IP, error)
+
+	// Port is the DNS port of the servers, the default is 53.
+	Port string
+}
+
+// Check returns nil if every server returns value among the TXT records of
+// fqdn, or an error naming each misbehaving server otherwise.
+func (c SelfChecker) Check(ctx context.Context, fqdn, value string) error {
+	ips, err := c.GetIPs(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	port := c.Port
+	if port == "" {
+		port = "53"
+	}
+	var cl dns.Client
+	cl.Timeout = 5 * time.Second
+	errs := make([]error, len(ips))
+	done := make(chan struct{})
+	for i, ip := range ips {
+		go func(i int, ip net.IP) {
+			defer func() { done <- struct{}{} }()
+			var m dns.Msg
+			m.SetQuestion(dns.Fqdn(fqdn), dns.TypeTXT)
+			m.RecursionDesired = false
+			r, _, err := cl.ExchangeContext(ctx, &m, net.JoinHostPort(ip.String(), port))
+			if err == nil && r.Rcode != dns.RcodeSuccess {
+				err = fmt.Errorf("rcode %s", dns.RcodeToString[r.Rcode])
+			}
+			if err == nil && !slices.Contains(txtValues(r), value) {
+				err = errors.New("challenge value not found")
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", ip, err)
+			}
+		}(i, ip)
+	}
+	for range ips {
+		<-done
+	}
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d peers misbehaving: %w", len(failed), len(ips), errors.Join(failed...))
+	}
+	return nil
+}
+
+func txtValues(r *dns.Msg) []string {
+	var values []string
+	for _, ans := range r.Answer {
+		if txt, ok := ans.(*dns.TXT); ok {
+			values = append(values, txt.Txt...)
+		}
+	}
+	return values
+}
@@ -0,0 +1,70 @@
+package dns01
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSelfChecker_Check(t *testing.T) {
+	// All the test servers listen on the same port of different loopback
+	// addresses, like the members of a cluster.
+	good := newTestTXTServer(t, "127.0.0.1:0", "value")
+	_, port, _ := net.SplitHostPort(good)
+	bad := newTestTXTServer(t, net.JoinHostPort("127.0.0.2", port), "other")
+
+	tests := []struct {
+		name    string
+		ips     []net.IP
+		wantErr string
+	}{
+		{"all good", []net.IP{net.ParseIP("127.0.0.1")}, ""},
+		{"one bad", []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}, "127.0.0.2: challenge value not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if bad == "" && tt.wantErr != "" {
+				t.Skip("127.0.0.2 not available")
+			}
+			c := SelfChecker{
+				GetIPs: func(context.Context, string) ([]net.IP, error) { return tt.ips, nil },
+				Port:   port,
+			}
+			err := c.Check(context.Background(), "_acme-challenge.example.com.", "value")
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), "127.0.0.1:") {
+				t.Errorf("Check() reported a good server: %v", err)
+			}
+		})
+	}
+}
+
+// newTestTXTServer starts a DNS server on addr answering TXT queries with
+// value and returns its address, or an empty string if addr is not available.
+func newTestTXTServer(t *testing.T, addr, value string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return ""
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		res := new(dns.Msg)
+		res.SetReply(r)
+		res.Answer = append(res.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+			Txt: []string{value},
+		})
+		w.WriteMsg(res)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
@@ -3,7 +3,7 @@ package dns01
 import (
 	"context"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"strings"
 	"time"
@@ -17,10 +17,26 @@ type Challenger interface {
 }
 
 type Server struct {
-	Zone                  string
+	// Zones lists the zones the server is authoritative for. Challenge queries
+	// outside of those zones are not handled. If empty, all challenge queries
+	// are handled.
+	Zones                 []string
 	GetNSIPs              func(ctx context.Context, fqdn string) ([]net.IP, error)
 	DistributedChallenger Challenger
 	LocalChallenger       Challenger
+
+	// Logger receives the logs of the server. It defaults to slog.Default().
+	Logger *slog.Logger
+
+	// OnQuery, if set, is called with the response code of each answered
+	// challenge query. The _local queries exchanged between the members of
+	// the cluster are not reported.
+	OnQuery func(rcode string)
+
+	// Context is the lifetime context of the server. Pending challenge
+	// lookups are cancelled once it is done. It defaults to
+	// context.Background().
+	Context context.Context
 }
 
 // ServeDNS handles a msg DNS query and writes a DNS response to w if the query
@@ -40,48 +56,97 @@ func (s Server) ServeDNS(msg []byte, w io.Writer) bool {
 		return false
 	}
 	fqdn := strings.ToLower(q.Name.String())
-	if q.Type == dnsmessage.TypeSOA && dns.Fqdn(s.Zone) == dns.Fqdn(q.Name.String()) {
+	if q.Type == dnsmessage.TypeSOA && s.isZone(fqdn) {
 		writeSOA(w, h, q)
 		return true
 	}
 	if !strings.HasPrefix(fqdn, "_acme-challenge.") && !strings.HasPrefix(fqdn, "_local_acme-challenge.") {
 		return false
 	}
+	if !s.inZones(fqdn) {
+		return false
+	}
 
 	go s.handleChanlenge(h, q, w)
 	return true
 }
 
+func (s Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func (s Server) context() context.Context {
+	if s.Context != nil {
+		return s.Context
+	}
+	return context.Background()
+}
+
+// isZone returns true if fqdn is the apex of one of the zones.
+func (s Server) isZone(fqdn string) bool {
+	for _, zone := range s.Zones {
+		if dns.Fqdn(strings.ToLower(zone)) == fqdn {
+			return true
+		}
+	}
+	return false
+}
+
+// inZones returns true if fqdn is part of one of the zones or if no zone is
+// configured.
+func (s Server) inZones(fqdn string) bool {
+	if len(s.Zones) == 0 {
+		return true
+	}
+	for _, zone := range s.Zones {
+		if dns.IsSubDomain(dns.Fqdn(strings.ToLower(zone)), fqdn) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s Server) handleChanlenge(h dnsmessage.Header, q dnsmessage.Question, w io.Writer) {
 	fqdn := strings.ToLower(q.Name.String())
 	challenge := fqdn
 	var c Challenger
+	var local bool
 	if strings.HasPrefix(fqdn, "_acme-challenge.") {
 		c = s.DistributedChallenger
 		challenge = "_local" + challenge
 	} else if strings.HasPrefix(fqdn, "_local_acme-challenge.") {
 		c = s.LocalChallenger
 		challenge = strings.TrimPrefix(challenge, "_local")
+		local = true
 	} else {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(s.context(), 3*time.Second)
 	defer cancel()
 
 	var challenges []string
 	if q.Type == dnsmessage.TypeTXT && c != nil {
 		var err error
 		if challenges, err = c.Challenge(ctx, challenge); err != nil {
-			log.Printf("Error getting challenges for %s: %v", fqdn, err)
+			s.logger().Warn("DNS-01 challenge lookup failed", slog.String("fqdn", fqdn), slog.Any("error", err))
 		}
 	}
-	log.Printf("DNS-01 %s %s: %v", strings.TrimPrefix(q.Type.String(), "Type"), fqdn, challenges)
+	s.logger().Debug("DNS-01 query",
+		slog.String("type", strings.TrimPrefix(q.Type.String(), "Type")),
+		slog.String("fqdn", fqdn),
+		slog.Any("challenges", challenges))
 
 	rcode := dnsmessage.RCodeNameError
 	if len(challenges) > 0 {
 		rcode = dnsmessage.RCodeSuccess
 	}
+	if s.OnQuery != nil && !local {
+		s.OnQuery(strings.TrimPrefix(rcode.String(), "RCode"))
+	}
 
 	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
 		ID:               h.ID,
@@ -0,0 +1,96 @@
+package dns01
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// blockingChallenger blocks until the lookup context is done and reports its
+// error.
+type blockingChallenger chan error
+
+func (c blockingChallenger) Challenge(ctx context.Context, fqdn string) ([]string, error) {
+	<-ctx.Done()
+	c <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+// writerFunc is an io.Writer calling itself.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestServer_ServeDNS_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lookups := make(blockingChallenger, 1)
+	s := Server{LocalChallenger: lookups, Context: ctx}
+
+	q := new(dns.Msg)
+	q.SetQuestion("_local_acme-challenge.example.com.", dns.TypeTXT)
+	msg, err := q.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	answered := make(chan *dns.Msg, 1)
+	w := writerFunc(func(p []byte) (int, error) {
+		r := new(dns.Msg)
+		if err := r.Unpack(p); err != nil {
+			t.Error(err)
+		}
+		answered <- r
+		return len(p), nil
+	})
+	if !s.ServeDNS(msg, w) {
+		t.Fatal("ServeDNS() = false, want true")
+	}
+
+	// Closing the server cancels the pending lookup before its timeout.
+	cancel()
+	select {
+	case err := <-lookups:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("lookup error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lookup not cancelled")
+	}
+	if r := <-answered; r.Rcode != dns.RcodeNameError {
+		t.Errorf("rcode = %s, want NXDOMAIN", dns.RcodeToString[r.Rcode])
+	}
+}
+
+func TestServer_ServeDNS_onQuery(t *testing.T) {
+	queries := make(chan string, 2)
+	s := Server{OnQuery: func(rcode string) { queries <- rcode }}
+	answered := make(chan struct{}, 2)
+	w := writerFunc(func(p []byte) (int, error) {
+		answered <- struct{}{}
+		return len(p), nil
+	})
+	for _, fqdn := range []string{"_acme-challenge.example.com.", "_local_acme-challenge.example.com."} {
+		q := new(dns.Msg)
+		q.SetQuestion(fqdn, dns.TypeTXT)
+		msg, err := q.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !s.ServeDNS(msg, w) {
+			t.Fatalf("ServeDNS(%s) = false, want true", fqdn)
+		}
+		<-answered
+	}
+
+	// Only the query of the CA is reported, not the one between members.
+	close(queries)
+	var got []string
+	for rcode := range queries {
+		got = append(got, rcode)
+	}
+	if len(got) != 1 || got[0] != "NameError" {
+		t.Errorf("OnQuery calls = %q, want [NameError]", got)
+	}
+}
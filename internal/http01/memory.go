@@ -0,0 +1,37 @@
+// Package http01 keeps the HTTP-01 challenges presented by the ACME client so
+// the HTTP handler can answer the validation requests of the CA.
+package http01
+
+import "sync"
+
+// MemoryProvider is a challenge.Provider that stores the key authorizations of
+// the pending challenges in memory.
+type MemoryProvider struct {
+	mu         sync.RWMutex
+	challenges map[string]string
+}
+
+func (p *MemoryProvider) Present(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.challenges == nil {
+		p.challenges = map[string]string{}
+	}
+	p.challenges[token] = keyAuth
+	return nil
+}
+
+func (p *MemoryProvider) CleanUp(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.challenges, token)
+	return nil
+}
+
+// KeyAuth returns the key authorization of the pending challenge for token.
+func (p *MemoryProvider) KeyAuth(token string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	keyAuth, found := p.challenges[token]
+	return keyAuth, found
+}
@@ -0,0 +1,84 @@
+package peer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// ErrChallengeNotFound is returned by Challenge when no peer knows the
+// challenge.
+var ErrChallengeNotFound = errors.New("challenge not found")
+
+// Challenge asks every peer for the value of the pending challenge identified
+// by key and returns the first value found.
+func (c Client) Challenge(ctx context.Context, key string) (string, error) {
+	ips, err := c.IPs(ctx)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		value string
+		err   error
+	}
+	results := make(chan result, len(ips))
+	for _, ip := range ips {
+		go func(ip net.IP) {
+			value, err := c.challenge(ctx, ip, key)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", ip, err)
+			}
+			results <- result{value, err}
+		}(ip)
+	}
+	var errs []error
+	for range ips {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+		if r.value != "" {
+			return r.value, nil
+		}
+	}
+	if len(errs) == len(ips) && len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return "", ErrChallengeNotFound
+}
+
+func (c Client) challenge(ctx context.Context, ip net.IP, key string) (string, error) {
+	conn, err := c.Dial(ctx, ip, ProtoChallenge)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "%s\n", key); err != nil {
+		return "", err
+	}
+	value, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// ServeChallenge reads the key of a challenge on rw and writes its value as
+// returned by lookup, or an empty line if not found.
+func ServeChallenge(rw io.ReadWriter, lookup func(key string) (string, bool)) error {
+	key, err := bufio.NewReader(io.LimitReader(rw, 1024)).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	value, _ := lookup(strings.TrimSpace(key))
+	_, err = fmt.Fprintf(rw, "%s\n", value)
+	return err
+}
@@ -0,0 +1,72 @@
+package peer
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+)
+
+// CertInfo describes a key pair served by a peer.
+type CertInfo struct {
+	// Fingerprint is the hex encoded SHA-256 hash of the leaf certificate.
+	Fingerprint string    `json:"fingerprint"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+
+	// OCSPStaple is the OCSP response stapled to the key pair, if any.
+	OCSPStaple []byte `json:"ocsp_staple,omitempty"`
+}
+
+// NewCertInfos returns the metadata of certs. Key pairs with an invalid leaf
+// are skipped.
+func NewCertInfos(certs []*tls.Certificate) []CertInfo {
+	infos := make([]CertInfo, 0, len(certs))
+	for _, cert := range certs {
+		if len(cert.Certificate) == 0 {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			continue
+		}
+		infos = append(infos, CertInfo{
+			Fingerprint: Fingerprint(leaf.Raw),
+			NotBefore:   leaf.NotBefore,
+			NotAfter:    leaf.NotAfter,
+			OCSPStaple:  cert.OCSPStaple,
+		})
+	}
+	return infos
+}
+
+// Fingerprint returns the hex encoded SHA-256 hash of a DER certificate.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// Info returns the metadata and OCSP staples of the key pairs of the peer at
+// ip.
+func (c Client) Info(ctx context.Context, ip net.IP) ([]CertInfo, error) {
+	conn, err := c.Dial(ctx, ip, ProtoInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var infos []CertInfo
+	if err := json.NewDecoder(io.LimitReader(conn, maxPushSize)).Decode(&infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// ServeInfo writes the metadata of certs to w.
+func ServeInfo(w io.Writer, certs []*tls.Certificate) error {
+	return json.NewEncoder(w).Encode(NewCertInfos(certs))
+}
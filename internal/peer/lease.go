@@ -0,0 +1,165 @@
+package peer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lease grants the right to order a certificate to a single member of the
+// cluster at a time. Each member holds its own Lease and a member may order a
+// certificate once all the reachable members granted it their lease.
+type Lease struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+// Acquire grants the lease to id for ttl and returns true if the lease is
+// free, expired or already held by id.
+func (l *Lease) Acquire(id string, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.holder != "" && l.holder != id && now.Before(l.expires) {
+		return false
+	}
+	l.holder = id
+	l.expires = now.Add(ttl)
+	return true
+}
+
+// Release releases the lease if held by id.
+func (l *Lease) Release(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == id {
+		l.holder = ""
+	}
+}
+
+// Serve handles a single lease request read from rw and writes the response.
+//
+// Requests are a single line, either "acquire <id> <ttl seconds>" or
+// "release <id>". Acquire requests are answered with "granted" or "denied".
+func (l *Lease) Serve(rw io.ReadWriter) error {
+	line, err := bufio.NewReader(io.LimitReader(rw, 256)).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 3 && fields[0] == "acquire":
+		ttl, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("invalid ttl: %w", err)
+		}
+		resp := "denied\n"
+		if l.Acquire(fields[1], time.Duration(ttl)*time.Second) {
+			resp = "granted\n"
+		}
+		_, err = io.WriteString(rw, resp)
+		return err
+	case len(fields) == 2 && fields[0] == "release":
+		l.Release(fields[1])
+		return nil
+	}
+	return fmt.Errorf("invalid lease request: %q", line)
+}
+
+// AcquireLease asks every peer to grant its lease to id for ttl and returns
+// true if all the reachable peers granted it. If a peer denies it, the leases
+// granted by the others are released so the competing member can proceed.
+// Unreachable peers are ignored so the cluster can still renew its
+// certificate when some members are down.
+func (c Client) AcquireLease(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	ips, err := c.IPs(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	type result struct {
+		ip      net.IP
+		granted bool
+		err     error
+	}
+	results := make(chan result, len(ips))
+	for _, ip := range ips {
+		go func(ip net.IP) {
+			granted, err := c.acquireLease(ctx, ip, id, ttl)
+			results <- result{ip, granted, err}
+		}(ip)
+	}
+
+	var granted []net.IP
+	var errs []error
+	denied := false
+	for range ips {
+		r := <-results
+		switch {
+		case r.err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", r.ip, r.err))
+		case r.granted:
+			granted = append(granted, r.ip)
+		default:
+			denied = true
+		}
+	}
+	if len(ips) > 0 && len(errs) == len(ips) {
+		return false, errors.Join(errs...)
+	}
+	if denied {
+		c.releaseLease(ctx, granted, id)
+		return false, nil
+	}
+	return true, nil
+}
+
+// ReleaseLease releases the lease held by id on every peer.
+func (c Client) ReleaseLease(ctx context.Context, id string) {
+	ips, err := c.IPs(ctx)
+	if err != nil {
+		return
+	}
+	c.releaseLease(ctx, ips, id)
+}
+
+func (c Client) releaseLease(ctx context.Context, ips []net.IP, id string) {
+	var wg sync.WaitGroup
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			conn, err := c.Dial(ctx, ip, ProtoLease)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			fmt.Fprintf(conn, "release %s\n", id)
+		}(ip)
+	}
+	wg.Wait()
+}
+
+func (c Client) acquireLease(ctx context.Context, ip net.IP, id string, ttl time.Duration) (bool, error) {
+	conn, err := c.Dial(ctx, ip, ProtoLease)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := fmt.Fprintf(conn, "acquire %s %d\n", id, int(ttl.Seconds())); err != nil {
+		return false, err
+	}
+	resp, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(resp) == "granted", nil
+}
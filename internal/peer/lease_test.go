@@ -0,0 +1,63 @@
+package peer
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLease_Acquire(t *testing.T) {
+	var l Lease
+	if !l.Acquire("a", time.Minute) {
+		t.Fatal("Acquire(a) on free lease = false")
+	}
+	if !l.Acquire("a", time.Minute) {
+		t.Error("Acquire(a) by holder = false")
+	}
+	if l.Acquire("b", time.Minute) {
+		t.Error("Acquire(b) on held lease = true")
+	}
+	l.Release("b")
+	if l.Acquire("b", time.Minute) {
+		t.Error("Acquire(b) after release by non-holder = true")
+	}
+	l.Release("a")
+	if !l.Acquire("b", time.Millisecond) {
+		t.Error("Acquire(b) after release = false")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if !l.Acquire("a", time.Minute) {
+		t.Error("Acquire(a) on expired lease = false")
+	}
+}
+
+func TestLease_Serve(t *testing.T) {
+	var l Lease
+	request := func(req string) string {
+		t.Helper()
+		client, server := net.Pipe()
+		defer client.Close()
+		go func() {
+			defer server.Close()
+			if err := l.Serve(server); err != nil {
+				t.Errorf("Serve() error = %v", err)
+			}
+		}()
+		fmt.Fprint(client, req)
+		resp, _ := bufio.NewReader(client).ReadString('\n')
+		return resp
+	}
+
+	if got := request("acquire a 60\n"); got != "granted\n" {
+		t.Errorf("acquire a = %q, want granted", got)
+	}
+	if got := request("acquire b 60\n"); got != "denied\n" {
+		t.Errorf("acquire b = %q, want denied", got)
+	}
+	request("release a\n")
+	if got := request("acquire b 60\n"); got != "granted\n" {
+		t.Errorf("acquire b after release = %q, want granted", got)
+	}
+}
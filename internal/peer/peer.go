@@ -0,0 +1,114 @@
+// Package peer implements the communication between the members of a cluster
+// over mutually authenticated TLS connections. Each operation uses its own
+// ALPN protocol so a server can tell which operation a client performs as
+// soon as the handshake completes.
+package peer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+const (
+	// ProtoFetch is the protocol used to fetch the key pairs of a peer. The
+	// server writes its key pairs encoded as PEM and closes the connection.
+	ProtoFetch = "zerocert"
+
+	// ProtoLease is the protocol used to acquire or release the issuance
+	// lease of a peer. See Lease.
+	ProtoLease = "zerocert-lease"
+
+	// ProtoPush is the protocol used to push key pairs to a peer. The client
+	// writes its key pairs encoded as PEM and closes its side of the
+	// connection. The server answers with a status line, "ok" if the key
+	// pairs were accepted or the reason why they were not.
+	ProtoPush = "zerocert-push"
+
+	// ProtoInfo is the protocol used to get the metadata and OCSP staples of
+	// the key pairs of a peer without transferring them. The server writes a
+	// JSON array of CertInfo and closes the connection.
+	ProtoInfo = "zerocert-info"
+
+	// ProtoPurge is the protocol used to tell a peer to stop serving revoked
+	// certificates. The client writes their fingerprints, one per line, and
+	// closes its side of the connection. The server answers with a status
+	// line like for ProtoPush.
+	ProtoPurge = "zerocert-purge"
+
+	// ProtoRevoked is the protocol used to tell a peer that the CA revoked
+	// certificates, which it replaces as soon as possible. The request and
+	// the response are like for ProtoPurge.
+	ProtoRevoked = "zerocert-revoked"
+
+	// ProtoChallenge is the protocol used to ask a peer for the value of a
+	// pending ACME challenge it presented. The client writes the key of the
+	// challenge on a line and the server answers with its value on a line,
+	// empty if not found.
+	ProtoChallenge = "zerocert-challenge"
+)
+
+// Protos lists the protocols supported by the server side.
+var Protos = []string{ProtoFetch, ProtoLease, ProtoPush, ProtoInfo, ProtoPurge, ProtoRevoked, ProtoChallenge}
+
+// Client connects to the members of a cluster.
+type Client struct {
+	// Port to connect to, the default is 443.
+	Port string
+
+	// GetIPs is a function that returns the IP addresses of the peers.
+	GetIPs func(ctx context.Context) ([]net.IP, error)
+
+	TLSDialer *tls.Dialer
+}
+
+var defaultTLSDialer = &tls.Dialer{}
+
+// IPs returns the IP addresses of the peers.
+func (c Client) IPs(ctx context.Context) ([]net.IP, error) {
+	if c.GetIPs == nil {
+		return nil, errors.New("GetIPs is not set")
+	}
+	return c.GetIPs(ctx)
+}
+
+// Dial connects to the peer at ip for the operation identified by proto. The
+// deadline of ctx, if any, applies to the whole connection.
+func (c Client) Dial(ctx context.Context, ip net.IP, proto string) (*tls.Conn, error) {
+	port := c.Port
+	if port == "" {
+		port = "443"
+	}
+	d := *defaultTLSDialer
+	if c.TLSDialer != nil {
+		d = *c.TLSDialer
+	}
+	if d.Config != nil {
+		d.Config = d.Config.Clone()
+	} else {
+		d.Config = &tls.Config{}
+	}
+	d.Config.NextProtos = []string{proto}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn.(*tls.Conn), nil
+}
+
+// Fetch returns the key pairs of the peer at ip.
+func (c Client) Fetch(ctx context.Context, ip net.IP) ([]*tls.Certificate, error) {
+	conn, err := c.Dial(ctx, ip, ProtoFetch)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return tlsutil.ParseKeyPairs(io.ReadAll(conn))
+}
@@ -0,0 +1,82 @@
+package peer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+func TestClient_hungPeer(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := tlsutil.GenerateDeterministicCA(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tlsutil.GenerateCertificate(ca, key, "zerocert", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The peer completes the handshake but never answers.
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go conn.(*tls.Conn).Handshake()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	ip := net.IPv4(127, 0, 0, 1)
+	c := Client{
+		Port:      port,
+		GetIPs:    func(context.Context) ([]net.IP, error) { return []net.IP{ip}, nil },
+		TLSDialer: &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	tests := map[string]func(ctx context.Context) error{
+		"Fetch": func(ctx context.Context) error {
+			_, err := c.Fetch(ctx, ip)
+			return err
+		},
+		"Info": func(ctx context.Context) error {
+			_, err := c.Info(ctx, ip)
+			return err
+		},
+		"AcquireLease": func(ctx context.Context) error {
+			_, err := c.AcquireLease(ctx, "a", time.Minute)
+			return err
+		},
+	}
+	for name, call := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- call(ctx) }()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Error("error = nil, want a timeout")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("not bounded by the context deadline")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package peer
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Purge tells every peer to stop serving the certificates with the given
+// fingerprints, as returned by Fingerprint, and returns the errors of the
+// peers that could not be reached or rejected the request.
+func (c Client) Purge(ctx context.Context, fingerprints []string) error {
+	return c.broadcast(ctx, ProtoPurge, []byte(strings.Join(fingerprints, "\n")+"\n"))
+}
+
+// ServePurge reads the fingerprints sent by a peer on rw, passes them to
+// purge and writes the status line.
+func ServePurge(rw io.ReadWriter, purge func(fingerprints []string) error) error {
+	return serveRequest(rw, func(b []byte) error {
+		return purge(strings.Fields(string(b)))
+	})
+}
+
+// Revoked tells every peer that the CA revoked the certificates with the given
+// fingerprints, and returns the errors of the peers that could not be reached
+// or rejected the request.
+func (c Client) Revoked(ctx context.Context, fingerprints []string) error {
+	return c.broadcast(ctx, ProtoRevoked, []byte(strings.Join(fingerprints, "\n")+"\n"))
+}
+
+// ServeRevoked reads the fingerprints sent by a peer on rw, passes them to
+// revoked and writes the status line.
+func ServeRevoked(rw io.ReadWriter, revoked func(fingerprints []string) error) error {
+	return ServePurge(rw, revoked)
+}
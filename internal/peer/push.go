@@ -0,0 +1,106 @@
+package peer
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+// maxPushSize bounds the size of the requests accepted from a peer.
+const maxPushSize = 1 << 20
+
+// Push sends certs to every peer and returns the errors of the peers that
+// could not be reached or rejected them.
+func (c Client) Push(ctx context.Context, certs []*tls.Certificate) error {
+	b, err := tlsutil.EncodeKeyPairs(certs)
+	if err != nil {
+		return err
+	}
+	return c.broadcast(ctx, ProtoPush, b)
+}
+
+// ServePush reads the key pairs pushed by a peer on rw, passes them to accept
+// and writes the status line.
+func ServePush(rw io.ReadWriter, accept func([]*tls.Certificate) error) error {
+	return serveRequest(rw, func(b []byte) error {
+		certs, err := tlsutil.ParseKeyPairs(b, nil)
+		if err != nil {
+			return err
+		}
+		return accept(certs)
+	})
+}
+
+// broadcast sends the request b to every peer using proto and returns the
+// errors of the peers that could not be reached or did not answer "ok".
+func (c Client) broadcast(ctx context.Context, proto string, b []byte) error {
+	ips, err := c.IPs(ctx)
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, len(ips))
+	for _, ip := range ips {
+		go func(ip net.IP) {
+			if err := c.request(ctx, ip, proto, b); err != nil {
+				errs <- fmt.Errorf("%s: %w", ip, err)
+				return
+			}
+			errs <- nil
+		}(ip)
+	}
+	var all []error
+	for range ips {
+		if err := <-errs; err != nil {
+			all = append(all, err)
+		}
+	}
+	return errors.Join(all...)
+}
+
+// request writes b to the peer at ip, closes the write side of the connection
+// and reads the status line.
+func (c Client) request(ctx context.Context, ip net.IP, proto string, b []byte) error {
+	conn, err := c.Dial(ctx, ip, proto)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+	if err := conn.CloseWrite(); err != nil {
+		return err
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if status = strings.TrimSpace(status); status != "ok" {
+		return fmt.Errorf("rejected: %s", status)
+	}
+	return nil
+}
+
+// serveRequest reads a request on rw, passes it to handle and writes the
+// status line.
+func serveRequest(rw io.ReadWriter, handle func([]byte) error) error {
+	b, err := io.ReadAll(io.LimitReader(rw, maxPushSize))
+	if err == nil {
+		err = handle(b)
+	}
+	status := "ok"
+	if err != nil {
+		status = strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+	if _, werr := fmt.Fprintf(rw, "%s\n", status); werr != nil {
+		return werr
+	}
+	return err
+}
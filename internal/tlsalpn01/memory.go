@@ -0,0 +1,43 @@
+// Package tlsalpn01 keeps the TLS-ALPN-01 challenges presented by the ACME
+// client so the TLS listener can answer the validation connections of the CA.
+package tlsalpn01
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryProvider is a challenge.Provider that stores the key authorizations of
+// the pending challenges in memory.
+type MemoryProvider struct {
+	mu         sync.RWMutex
+	challenges map[string]string
+}
+
+func (p *MemoryProvider) Present(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.challenges == nil {
+		p.challenges = map[string]string{}
+	}
+	p.challenges[strings.ToLower(domain)] = keyAuth
+	return nil
+}
+
+func (p *MemoryProvider) CleanUp(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	domain = strings.ToLower(domain)
+	if p.challenges[domain] == keyAuth {
+		delete(p.challenges, domain)
+	}
+	return nil
+}
+
+// KeyAuth returns the key authorization of the pending challenge for domain.
+func (p *MemoryProvider) KeyAuth(domain string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	keyAuth, found := p.challenges[strings.ToLower(domain)]
+	return keyAuth, found
+}
@@ -98,22 +98,26 @@ func GenerateCertificate(caCert *x509.Certificate, caKey *ecdsa.PrivateKey, doma
 	return tls.X509KeyPair(pemBytes, pemBytes)
 }
 
-func LatestCertificate(certs []*tls.Certificate) (*tls.Certificate, error) {
+// LatestKeyPairs returns the most recently issued set of key pairs, as defined
+// by the NotBefore date of the leaf of its first certificate. The expiration
+// date is not used as a renewed certificate may have a shorter lifetime than
+// the one it replaces. Nil or empty sets are ignored.
+func LatestKeyPairs(sets [][]*tls.Certificate) ([]*tls.Certificate, error) {
 	var errs []error
-	var latest *tls.Certificate
-	var latestNotAfter time.Time
-	for _, cert := range certs {
-		if latest == nil {
-			latest = cert
+	var latest []*tls.Certificate
+	var latestNotBefore time.Time
+	for _, certs := range sets {
+		if len(certs) == 0 {
 			continue
 		}
-		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		x509Cert, err := x509.ParseCertificate(certs[0].Certificate[0])
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		if x509Cert.NotAfter.After(latestNotAfter) {
-			latest = cert
+		if latest == nil || x509Cert.NotBefore.After(latestNotBefore) {
+			latest = certs
+			latestNotBefore = x509Cert.NotBefore
 		}
 	}
 
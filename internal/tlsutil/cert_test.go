@@ -0,0 +1,60 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestLatestKeyPairs(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	keyPairs := func(notBefore time.Time, lifetime time.Duration) []*tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			DNSNames:     []string{"example.com"},
+			NotBefore:    notBefore,
+			NotAfter:     notBefore.Add(lifetime),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []*tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}
+	}
+	old := keyPairs(now.Add(-30*day), 90*day)
+	renewed := keyPairs(now.Add(-day), 90*day)
+	shortLived := keyPairs(now.Add(-time.Hour), 6*day)
+
+	tests := []struct {
+		name string
+		sets [][]*tls.Certificate
+		want []*tls.Certificate
+	}{
+		{"empty", nil, nil},
+		{"single", [][]*tls.Certificate{old}, old},
+		{"renewed", [][]*tls.Certificate{old, renewed}, renewed},
+		{"renewed first", [][]*tls.Certificate{renewed, nil, old}, renewed},
+		{"shorter lifetime", [][]*tls.Certificate{old, shortLived, renewed}, shortLived},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LatestKeyPairs(tt.sets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) || len(got) > 0 && got[0] != tt.want[0] {
+				t.Errorf("LatestKeyPairs() did not return the expected set")
+			}
+		})
+	}
+}
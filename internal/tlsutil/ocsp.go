@@ -0,0 +1,90 @@
+package tlsutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ErrNoOCSPServer is returned by FetchOCSP when the certificate does not
+// advertise an OCSP responder.
+var ErrNoOCSPServer = errors.New("no OCSP server")
+
+// maxOCSPResponseSize bounds the size of the responses read from an OCSP
+// responder.
+const maxOCSPResponseSize = 1 << 20
+
+// FetchOCSP queries the OCSP responder of the leaf of cert and returns the
+// raw response along with its parsed form. The chain of cert must include the
+// issuer of the leaf. A nil client selects a client with a timeout.
+func FetchOCSP(ctx context.Context, client *http.Client, cert *tls.Certificate) ([]byte, *ocsp.Response, error) {
+	leaf, issuer, err := leafAndIssuer(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil, ErrNoOCSPServer
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/ocsp-request")
+	res, err := httpClient(client).Do(hreq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("OCSP responder: %s", res.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, resp, nil
+}
+
+// ParseOCSPStaple parses and verifies the OCSP staple of cert. It returns nil
+// and no error if cert has no staple.
+func ParseOCSPStaple(cert *tls.Certificate) (*ocsp.Response, error) {
+	if len(cert.OCSPStaple) == 0 {
+		return nil, nil
+	}
+	leaf, issuer, err := leafAndIssuer(cert)
+	if err != nil {
+		return nil, err
+	}
+	return ocsp.ParseResponseForCert(cert.OCSPStaple, leaf, issuer)
+}
+
+// leafAndIssuer returns the parsed leaf of cert and its issuer.
+func leafAndIssuer(cert *tls.Certificate) (*x509.Certificate, *x509.Certificate, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, nil, errors.New("certificate chain without issuer")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return leaf, issuer, nil
+}
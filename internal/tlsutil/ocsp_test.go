@@ -0,0 +1,145 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestFetchOCSP(t *testing.T) {
+	cert := revocationTestCertificate(t, ocsp.Good, true)
+
+	raw, resp, err := FetchOCSP(context.Background(), nil, cert)
+	if err != nil {
+		t.Fatalf("FetchOCSP() error = %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("FetchOCSP() status = %d, want %d", resp.Status, ocsp.Good)
+	}
+
+	cert.OCSPStaple = raw
+	b, err := EncodeKeyPairs([]*tls.Certificate{cert})
+	if err != nil {
+		t.Fatalf("EncodeKeyPairs() error = %v", err)
+	}
+	got, err := ParseKeyPairs(b, nil)
+	if err != nil {
+		t.Fatalf("ParseKeyPairs() error = %v", err)
+	}
+	staple, err := ParseOCSPStaple(got[0])
+	if err != nil {
+		t.Fatalf("ParseOCSPStaple() error = %v", err)
+	}
+	if staple == nil || staple.SerialNumber.Cmp(resp.SerialNumber) != 0 {
+		t.Errorf("OCSP staple not preserved by the PEM round trip")
+	}
+}
+
+// revocationTestCertificate returns a certificate signed by a test CA that
+// reports it with status over OCSP, if withOCSP is true, and over CRL.
+func revocationTestCertificate(t *testing.T, status int, withOCSP bool) *tls.Certificate {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial := big.NewInt(2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/crl" {
+			var entries []x509.RevocationListEntry
+			if status == ocsp.Revoked {
+				entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now().Add(-time.Minute)})
+			}
+			crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				Number:                    big.NewInt(1),
+				ThisUpdate:                time.Now().Add(-time.Minute),
+				NextUpdate:                time.Now().Add(time.Hour),
+				RevokedCertificateEntries: entries,
+			}, ca, caKey)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(crl)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: []string{srv.URL + "/crl"},
+	}
+	if withOCSP {
+		template.OCSPServer = []string{srv.URL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der, caDER},
+		PrivateKey:  key,
+	}
+}
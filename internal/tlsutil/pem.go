@@ -7,9 +7,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// ocspBlockType is the PEM block type of OCSP staples.
+const ocspBlockType = "OCSP RESPONSE"
+
 // Load EC private key from bytes
 func LoadECPrivateKey(key []byte) (*ecdsa.PrivateKey, error) {
 	block, _ := pem.Decode(key)
@@ -20,22 +25,71 @@ func LoadECPrivateKey(key []byte) (*ecdsa.PrivateKey, error) {
 	return x509.ParseECPrivateKey(block.Bytes)
 }
 
-// ParseKeyPair parses a PEM-encoded certificate and private key form the same
-// PEM. It takes the output of a os.ReadFile or io.ReadAll call as input and
-// passes its error down if non-nil.
-func ParseKeyPair(pem []byte, err error) (*tls.Certificate, error) {
+// ParseKeyPairs parses a PEM that contains one or more certificate chains, each
+// followed by its private key and optionally its OCSP staple, as produced by
+// EncodeKeyPairs. It takes the output of a os.ReadFile or io.ReadAll call as
+// input and passes its error down if non-nil. An empty input returns no key
+// pair and no error.
+func ParseKeyPairs(b []byte, err error) ([]*tls.Certificate, error) {
 	if err != nil {
 		return nil, err
 	}
-	cert, err := tls.X509KeyPair(pem, pem)
-	if err != nil {
-		return nil, err
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil, nil
+	}
+	var certs []*tls.Certificate
+	var chain []byte
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, pem.EncodeToMemory(block)...)
+			continue
+		}
+		if block.Type == ocspBlockType {
+			if len(certs) > 0 && len(chain) == 0 {
+				certs[len(certs)-1].OCSPStaple = block.Bytes
+			}
+			continue
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		cert, err := tls.X509KeyPair(chain, pem.EncodeToMemory(block))
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, &cert)
+		chain = nil
+	}
+	if len(chain) > 0 {
+		return nil, errors.New("certificate without private key")
 	}
-	return &cert, nil
+	if len(certs) == 0 {
+		return nil, errors.New("no key pair found")
+	}
+	return certs, nil
+}
+
+// EncodeKeyPairs encodes certs into a single PEM as a sequence of key pairs
+// encoded with EncodeKeyPair.
+func EncodeKeyPairs(certs []*tls.Certificate) ([]byte, error) {
+	var buf []byte
+	for _, cert := range certs {
+		b, err := EncodeKeyPair(cert)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+	return buf, nil
 }
 
 // EncodeKeyPair encodes a tls.Certificate into a single PEM block that contains
-// both the certificate and private key.
+// both the certificate and private key, followed by the OCSP staple if any.
 func EncodeKeyPair(cert *tls.Certificate) ([]byte, error) {
 	var buf bytes.Buffer
 
@@ -86,5 +140,14 @@ func EncodeKeyPair(cert *tls.Certificate) ([]byte, error) {
 		return nil, fmt.Errorf("failed to encode private key: %w", err)
 	}
 
+	if len(cert.OCSPStaple) > 0 {
+		if err := pem.Encode(&buf, &pem.Block{
+			Type:  ocspBlockType,
+			Bytes: cert.OCSPStaple,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to encode OCSP staple: %w", err)
+		}
+	}
+
 	return buf.Bytes(), nil
 }
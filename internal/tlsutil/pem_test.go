@@ -0,0 +1,108 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestKeyPairRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		genKey func() (crypto.Signer, error)
+	}{
+		{"EC256", func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) }},
+		{"EC384", func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) }},
+		{"RSA2048", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) }},
+		{"RSA3072", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 3072) }},
+		{"RSA4096", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 4096) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.genKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert := selfSigned(t, key)
+
+			b, err := EncodeKeyPairs([]*tls.Certificate{cert})
+			if err != nil {
+				t.Fatalf("EncodeKeyPairs() error = %v", err)
+			}
+			got, err := ParseKeyPairs(b, nil)
+			if err != nil {
+				t.Fatalf("ParseKeyPairs() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("ParseKeyPairs() returned %d key pairs, want 1", len(got))
+			}
+			assertKeyPair(t, got[0], cert)
+		})
+	}
+}
+
+func TestKeyPairsRoundTrip(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := []*tls.Certificate{selfSigned(t, ecKey), selfSigned(t, rsaKey)}
+
+	b, err := EncodeKeyPairs(certs)
+	if err != nil {
+		t.Fatalf("EncodeKeyPairs() error = %v", err)
+	}
+	got, err := ParseKeyPairs(b, nil)
+	if err != nil {
+		t.Fatalf("ParseKeyPairs() error = %v", err)
+	}
+	if len(got) != len(certs) {
+		t.Fatalf("ParseKeyPairs() returned %d key pairs, want %d", len(got), len(certs))
+	}
+	for i := range certs {
+		assertKeyPair(t, got[i], certs[i])
+	}
+}
+
+func assertKeyPair(t *testing.T, got, want *tls.Certificate) {
+	t.Helper()
+	if len(got.Certificate) != len(want.Certificate) || !bytes.Equal(got.Certificate[0], want.Certificate[0]) {
+		t.Errorf("certificate mismatch")
+	}
+	wantPub := want.PrivateKey.(crypto.Signer).Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !wantPub.Equal(got.PrivateKey.(crypto.Signer).Public()) {
+		t.Errorf("private key mismatch")
+	}
+}
+
+func selfSigned(t *testing.T, key crypto.Signer) *tls.Certificate {
+	t.Helper()
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
@@ -0,0 +1,149 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// ErrNoRevocationInfo is returned by CheckRevocation when the certificate
+// advertises neither an OCSP responder nor a CRL distribution point.
+var ErrNoRevocationInfo = errors.New("no revocation information")
+
+// maxCRLSize bounds the size of the CRLs downloaded by CheckRevocation.
+const maxCRLSize = 16 << 20
+
+// CheckRevocation returns true if the leaf of cert has been revoked by its
+// issuer. OCSP is used if available, with a fallback on the CRL distribution
+// points. The chain of cert must include the issuer of the leaf. CRLs are not
+// cached, use a RevocationChecker to check certificates repeatedly.
+func CheckRevocation(ctx context.Context, client *http.Client, cert *tls.Certificate) (bool, error) {
+	return (&RevocationChecker{Client: client}).Check(ctx, cert)
+}
+
+// RevocationChecker checks the revocation status of certificates like
+// CheckRevocation, keeping the downloaded CRLs until their next update. The
+// zero value is ready to use.
+type RevocationChecker struct {
+	// Client is the HTTP client used to query OCSP responders and download
+	// CRLs. It defaults to a client with a timeout of 30 seconds.
+	Client *http.Client
+
+	mu   sync.Mutex
+	crls map[string]*x509.RevocationList
+}
+
+// Check returns true if the leaf of cert has been revoked by its issuer. See
+// CheckRevocation.
+func (c *RevocationChecker) Check(ctx context.Context, cert *tls.Certificate) (bool, error) {
+	_, resp, ocspErr := FetchOCSP(ctx, c.Client, cert)
+	if ocspErr == nil && resp.Status != ocsp.Unknown {
+		return resp.Status == ocsp.Revoked, nil
+	}
+	revoked, err := c.checkCRL(ctx, cert)
+	if errors.Is(err, ErrNoRevocationInfo) && ocspErr != nil && !errors.Is(ocspErr, ErrNoOCSPServer) {
+		return false, ocspErr
+	}
+	return revoked, err
+}
+
+// checkCRL looks for the leaf of cert in the CRLs of its distribution points.
+func (c *RevocationChecker) checkCRL(ctx context.Context, cert *tls.Certificate) (bool, error) {
+	leaf, issuer, err := leafAndIssuer(cert)
+	if err != nil {
+		return false, err
+	}
+	if len(leaf.CRLDistributionPoints) == 0 {
+		return false, ErrNoRevocationInfo
+	}
+	var errs []error
+	for _, url := range leaf.CRLDistributionPoints {
+		crl, err := c.crl(ctx, url, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, errors.Join(errs...)
+}
+
+// crl returns the CRL at url signed by issuer, from the cache if it is not
+// past its next update.
+func (c *RevocationChecker) crl(ctx context.Context, url string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	now := time.Now()
+	c.mu.Lock()
+	crl := c.crls[url]
+	c.mu.Unlock()
+	if crl != nil && now.Before(crl.NextUpdate) && crl.CheckSignatureFrom(issuer) == nil {
+		return crl, nil
+	}
+
+	crl, err := fetchCRL(ctx, httpClient(c.Client), url)
+	if err != nil {
+		return nil, err
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.crls == nil {
+		c.crls = make(map[string]*x509.RevocationList)
+	}
+	for u, cached := range c.crls {
+		if !now.Before(cached.NextUpdate) {
+			delete(c.crls, u)
+		}
+	}
+	if now.Before(crl.NextUpdate) {
+		c.crls[url] = crl
+	}
+	return crl, nil
+}
+
+func fetchCRL(ctx context.Context, client *http.Client, url string) (*x509.RevocationList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL server: %s", res.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxCRLSize))
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseRevocationList(b)
+}
+
+// defaultClient is the HTTP client used to check revocations when none is
+// provided. Unlike http.DefaultClient, it does not wait forever for a
+// hanging OCSP responder or CRL server.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// httpClient returns client or, if nil, defaultClient.
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return defaultClient
+	}
+	return client
+}
@@ -0,0 +1,60 @@
+package tlsutil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestCheckRevocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		withOCSP bool
+		want     bool
+	}{
+		{"OCSP good", ocsp.Good, true, false},
+		{"OCSP revoked", ocsp.Revoked, true, true},
+		{"CRL good", ocsp.Good, false, false},
+		{"CRL revoked", ocsp.Revoked, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := revocationTestCertificate(t, tt.status, tt.withOCSP)
+			got, err := CheckRevocation(context.Background(), nil, cert)
+			if err != nil {
+				t.Fatalf("CheckRevocation() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckRevocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationChecker_cachesCRL(t *testing.T) {
+	cert := revocationTestCertificate(t, ocsp.Good, false)
+	var requests int
+	c := RevocationChecker{Client: &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requests++
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}}
+	for range 3 {
+		if revoked, err := c.Check(context.Background(), cert); err != nil || revoked {
+			t.Fatalf("Check() = %v, %v, want false", revoked, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("CRL downloaded %d times, want 1", requests)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
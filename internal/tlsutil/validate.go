@@ -5,6 +5,9 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
 // ValidateClientCert checks if the provided client certificate is signed by the given CA certificate.
@@ -45,3 +48,45 @@ func ValidateClientCertFromTLS(tc tls.ConnectionState, caCert *x509.Certificate)
 	// Validate the client certificate against the CA
 	return ValidateClientCert(tc.PeerCertificates[0], caCert)
 }
+
+// CoversNames returns true if all names are listed in the subject alternative
+// names of cert.
+func CoversNames(cert *x509.Certificate, names []string) bool {
+	for _, name := range names {
+		if !slices.ContainsFunc(cert.DNSNames, func(dnsName string) bool {
+			return strings.EqualFold(dnsName, name)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateKeyPair checks that each certificate of cert's chain is signed by
+// the next one, that the leaf covers names and that it is valid at now.
+func ValidateKeyPair(cert *tls.Certificate, names []string, now time.Time) error {
+	if len(cert.Certificate) == 0 {
+		return errors.New("empty certificate chain")
+	}
+	chain := make([]*x509.Certificate, 0, len(cert.Certificate))
+	for _, der := range cert.Certificate {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, c)
+	}
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return fmt.Errorf("invalid chain: %w", err)
+		}
+	}
+	leaf := chain[0]
+	if !CoversNames(leaf, names) {
+		return fmt.Errorf("certificate does not cover %v", names)
+	}
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return errors.New("certificate not valid at this time")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestCoversNames(t *testing.T) {
+	cert := &x509.Certificate{DNSNames: []string{"*.example.com", "example.com"}}
+	tests := []struct {
+		name  string
+		names []string
+		want  bool
+	}{
+		{"all names", []string{"example.com", "*.example.com"}, true},
+		{"case insensitive", []string{"EXAMPLE.com"}, true},
+		{"no names", nil, true},
+		{"missing name", []string{"example.com", "example.net"}, false},
+		{"wildcard does not cover names", []string{"www.example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CoversNames(cert, tt.names); got != tt.want {
+				t.Errorf("CoversNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
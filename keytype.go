@@ -0,0 +1,73 @@
+package zerocert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// KeyType is the type of private key of the certificate.
+type KeyType string
+
+const (
+	// EC256 is an ECDSA key on the P-256 curve.
+	EC256 = KeyType(certcrypto.EC256)
+	// EC384 is an ECDSA key on the P-384 curve.
+	EC384 = KeyType(certcrypto.EC384)
+	// RSA2048 is a 2048 bits RSA key.
+	RSA2048 = KeyType(certcrypto.RSA2048)
+	// RSA3072 is a 3072 bits RSA key.
+	RSA3072 = KeyType(certcrypto.RSA3072)
+	// RSA4096 is a 4096 bits RSA key.
+	RSA4096 = KeyType(certcrypto.RSA4096)
+)
+
+func (t KeyType) valid() bool {
+	switch t {
+	case EC256, EC384, RSA2048, RSA3072, RSA4096:
+		return true
+	}
+	return false
+}
+
+// keyTypeOf returns the key type of cert's private key or an empty string if
+// unsupported.
+func keyTypeOf(cert *tls.Certificate) KeyType {
+	switch key := cert.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return EC256
+		case elliptic.P384():
+			return EC384
+		}
+	case *rsa.PrivateKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return RSA2048
+		case 3072:
+			return RSA3072
+		case 4096:
+			return RSA4096
+		}
+	}
+	return ""
+}
+
+// orderByKeyType returns the certificates of certs matching keyTypes in the
+// order of keyTypes. Certificates with other key types are dropped.
+func orderByKeyType(certs []*tls.Certificate, keyTypes []KeyType) []*tls.Certificate {
+	var ordered []*tls.Certificate
+	for _, keyType := range keyTypes {
+		for _, cert := range certs {
+			if keyTypeOf(cert) == keyType {
+				ordered = append(ordered, cert)
+				break
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,77 @@
+package zerocert
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// leaseTTL bounds the time a member can hold the issuance lease, so the
+	// cluster recovers if it dies while ordering a certificate.
+	leaseTTL = 10 * time.Minute
+
+	// leasePollInterval is the interval at which a member waiting for another
+	// one to obtain the certificate checks its peers.
+	leasePollInterval = 15 * time.Second
+)
+
+// acquireLease waits until all the reachable members of the cluster grant
+// their issuance lease to this member, so only one member orders a
+// certificate at a time. While waiting, and once the lease is granted, it
+// pulls the certificate from the caches and returns false if another member
+// obtained a fresh one.
+//
+// If no member can be reached, it returns true so the certificate can still
+// be obtained without coordination.
+func (m *Manager) acquireLease(ctx context.Context) (bool, error) {
+	for {
+		leaseCtx, cancel := context.WithTimeout(ctx, peerTimeout)
+		granted, err := m.peers.AcquireLease(leaseCtx, m.nodeID, leaseTTL)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			m.logger.Warn("issuance lease unavailable, proceeding without coordination",
+				slog.String("domain", m.names[0]), slog.Any("error", err))
+			return true, nil
+		}
+		if granted {
+			// The previous holder may have obtained the certificate since it
+			// was last looked for.
+			if m.reloadCache(ctx) {
+				m.releaseLease(ctx)
+				return false, nil
+			}
+			return true, nil
+		}
+
+		m.logger.Info("another member is ordering the certificate, waiting", slog.String("domain", m.names[0]))
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(jitter(leasePollInterval)):
+		}
+		if m.reloadCache(ctx) {
+			return false, nil
+		}
+	}
+}
+
+// reloadCache loads the certificate from the caches and returns true if it
+// does not need to be refreshed anymore.
+func (m *Manager) reloadCache(ctx context.Context) bool {
+	if err := m.loadCache(ctx); err != nil {
+		m.logger.Warn("loading certificate from cache failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+	}
+	return !m.needsRefresh(ctx)
+}
+
+// releaseLease releases the issuance lease held by this member so waiting
+// members can proceed without waiting for the lease to expire.
+func (m *Manager) releaseLease(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), peerTimeout)
+	defer cancel()
+	m.peers.ReleaseLease(ctx, m.nodeID)
+}
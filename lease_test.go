@@ -0,0 +1,106 @@
+package zerocert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManager_acquireLease(t *testing.T) {
+	acme := newTestACMEServer(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	holder, waiter := members[0], members[1]
+	ctx := context.Background()
+
+	granted, err := holder.acquireLease(ctx)
+	if err != nil || !granted {
+		t.Fatalf("holder acquireLease() = %v, %v, want true", granted, err)
+	}
+	// The waiter is denied the lease while the holder orders the certificate.
+	if granted, err := waiter.peers.AcquireLease(ctx, waiter.nodeID, leaseTTL); err != nil || granted {
+		t.Fatalf("waiter AcquireLease() = %v, %v, want false", granted, err)
+	}
+	if err := holder.obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	holder.releaseLease(ctx)
+
+	// Once granted the lease, the waiter finds the certificate obtained by
+	// the holder instead of ordering another one.
+	granted, err = waiter.acquireLease(ctx)
+	if err != nil || granted {
+		t.Errorf("waiter acquireLease() = %v, %v, want false", granted, err)
+	}
+	if err := waiter.LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	if orders, _ := acme.stats(); orders != 1 {
+		t.Errorf("CA got %d orders, want 1", orders)
+	}
+	if !leaf(waiter.getCertificates()).Equal(leaf(holder.getCertificates())) {
+		t.Error("waiter does not serve the certificate obtained by the holder")
+	}
+	// The waiter released the lease it did not use.
+	if granted, err := holder.peers.AcquireLease(ctx, holder.nodeID, leaseTTL); err != nil || !granted {
+		t.Errorf("holder AcquireLease() = %v, %v, want true", granted, err)
+	}
+}
+
+func TestManager_LoadOrRefresh_cancelledWhileWaitingLease(t *testing.T) {
+	acme := newTestACMEServer(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	holder, waiter := members[0], members[1]
+	if granted, err := holder.acquireLease(context.Background()); err != nil || !granted {
+		t.Fatalf("holder acquireLease() = %v, %v, want true", granted, err)
+	}
+
+	// The waiter polls the lease far less often than the context lasts.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := waiter.LoadOrRefresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LoadOrRefresh() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > leasePollInterval/2 {
+		t.Errorf("LoadOrRefresh() returned after %v", elapsed)
+	}
+	if orders, _ := acme.stats(); orders != 0 {
+		t.Errorf("CA got %d orders, want 0", orders)
+	}
+}
+
+func TestManager_LoadOrRefresh_concurrent(t *testing.T) {
+	acme := newTestACMEServer(t)
+	m := newTestCluster(t, 1, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})[0]
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			errs <- m.LoadOrRefresh(context.Background())
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("LoadOrRefresh() error = %v", err)
+		}
+	}
+	if orders, _ := acme.stats(); orders != 1 {
+		t.Errorf("CA got %d orders, want 1", orders)
+	}
+}
@@ -3,12 +3,16 @@ package zerocert
 import (
 	"context"
 	"crypto/tls"
-	"log"
+	"log/slog"
 	"net"
 	"runtime/debug"
+	"slices"
 	"sync"
 	"time"
 
+	legotlsalpn01 "github.com/go-acme/lego/v4/challenge/tlsalpn01"
+
+	"github.com/rs/zerocert/internal/peer"
 	"github.com/rs/zerocert/internal/tlsutil"
 )
 
@@ -16,8 +20,10 @@ type tlsListener struct {
 	net.Listener
 	m *Manager
 
-	initOnce sync.Once
-	c        chan connRes
+	initOnce  sync.Once
+	closeOnce sync.Once
+	c         chan connRes
+	done      chan struct{}
 }
 
 type connRes struct {
@@ -27,20 +33,47 @@ type connRes struct {
 
 func (l *tlsListener) Accept() (net.Conn, error) {
 	l.initOnce.Do(l.init)
-	res := <-l.c
-	return res.conn, res.err
+	select {
+	case res := <-l.c:
+		return res.conn, res.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the underlying listener and stops the connections waiting to
+// be accepted.
+func (l *tlsListener) Close() error {
+	l.initOnce.Do(l.init)
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
 }
 
 func (l *tlsListener) init() {
 	l.c = make(chan connRes)
+	l.done = make(chan struct{})
 	go l.acceptLoop()
 }
 
+// send passes res to Accept unless the listener is closed, in which case the
+// connection is closed.
+func (l *tlsListener) send(res connRes) {
+	select {
+	case l.c <- res:
+	case <-l.done:
+		if res.conn != nil {
+			res.conn.Close()
+		}
+	}
+}
+
 func (l *tlsListener) acceptLoop() {
 	for {
 		c, err := l.Listener.Accept()
 		if err != nil {
-			l.c <- connRes{nil, err}
+			l.send(connRes{nil, err})
 			return
 		}
 		tc := tls.Server(c, l.m.serverTLSConfig)
@@ -49,26 +82,35 @@ func (l *tlsListener) acceptLoop() {
 }
 
 func (l *tlsListener) handleConn(tc *tls.Conn) {
+	logger := l.m.logger.With(slog.String("peer", remoteIP(tc)))
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("Recovered from panic in handleConn: %v\n%s", r, debug.Stack())
+			logger.Error("recovered from panic in handleConn", slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
 			tc.Close()
 		}
 	}()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(l.m.ctx, 10*time.Second)
 	defer cancel()
 
-	if err := tc.HandshakeContext(ctx); err != nil {
+	err := tc.HandshakeContext(ctx)
+	state := tc.ConnectionState()
+	l.m.metrics.Handshake(state.ServerName == mTLSDomain, err)
+	if err != nil {
 		// Let upstream handle the handshake error.
-		l.c <- connRes{tc, nil}
+		l.send(connRes{tc, nil})
 		return
 	}
 
-	state := tc.ConnectionState()
-	if state.ServerName != mTLSDomain || state.NegotiatedProtocol != tlsProto {
+	if state.NegotiatedProtocol == legotlsalpn01.ACMETLS1Protocol {
+		// TLS-ALPN-01 validation connections end with the handshake.
+		tc.Close()
+		return
+	}
+
+	if state.ServerName != mTLSDomain || !slices.Contains(peer.Protos, state.NegotiatedProtocol) {
 		// Non-mTLS and non-zerocert proto connection are sent upstream.
-		l.c <- connRes{tc, nil}
+		l.send(connRes{tc, nil})
 		return
 	}
 
@@ -76,23 +118,75 @@ func (l *tlsListener) handleConn(tc *tls.Conn) {
 
 	// Ensure the client certificate is valid and signed by the private CA.
 	if err := tlsutil.ValidateClientCertFromTLS(state, l.m.caCert); err != nil {
-		log.Printf("cert request: client auth failed: %v", err)
+		logger.Warn("peer request: client auth failed", slog.Any("error", err))
 		return
 	}
+	tc.SetDeadline(time.Now().Add(10 * time.Second))
+
+	switch state.NegotiatedProtocol {
+	case peer.ProtoFetch:
+		l.serveFetch(tc, logger)
+	case peer.ProtoLease:
+		if err := l.m.lease.Serve(tc); err != nil {
+			logger.Warn("lease request failed", slog.Any("error", err))
+		}
+	case peer.ProtoPush:
+		err := peer.ServePush(tc, func(certs []*tls.Certificate) error {
+			return l.m.acceptPushed(ctx, certs)
+		})
+		if err != nil {
+			logger.Warn("push request rejected", slog.Any("error", err))
+		}
+	case peer.ProtoPurge:
+		err := peer.ServePurge(tc, func(fingerprints []string) error {
+			return l.m.purgeFromPeer(ctx, fingerprints)
+		})
+		if err != nil {
+			logger.Warn("purge request failed", slog.Any("error", err))
+		}
+	case peer.ProtoRevoked:
+		err := peer.ServeRevoked(tc, func(fingerprints []string) error {
+			l.m.revokedFromPeer(fingerprints)
+			return nil
+		})
+		if err != nil {
+			logger.Warn("revoked request failed", slog.Any("error", err))
+		}
+	case peer.ProtoChallenge:
+		if err := peer.ServeChallenge(tc, l.m.localChallenge); err != nil {
+			logger.Warn("challenge request failed", slog.Any("error", err))
+		}
+	case peer.ProtoInfo:
+		if err := peer.ServeInfo(tc, l.m.getCertificates()); err != nil {
+			logger.Warn("info request failed", slog.Any("error", err))
+		}
+	}
+}
 
-	// Send cert/key pair encoded as PEM
-	if cert := l.m.GetCertificate(); cert == nil {
-		log.Println("cert request: no certificate")
+// serveFetch sends the cert/key pairs encoded as PEM.
+func (l *tlsListener) serveFetch(tc *tls.Conn, logger *slog.Logger) {
+	certs := l.m.getCertificates()
+	if len(certs) == 0 {
+		logger.Debug("cert request: no certificate")
 		return
 	}
 
-	b, err := tlsutil.EncodeKeyPair(l.m.GetCertificate())
+	b, err := tlsutil.EncodeKeyPairs(certs)
 	if err != nil {
-		log.Printf("cert request: encoding: %v", err)
+		logger.Error("cert request: encoding failed", slog.Any("error", err))
 		return
 	}
 
 	if _, err = tc.Write(b); err != nil {
-		log.Printf("cert request: write: %v", err)
+		logger.Warn("cert request: write failed", slog.Any("error", err))
+	}
+}
+
+// remoteIP returns the IP address of the remote end of c.
+func remoteIP(c net.Conn) string {
+	host, _, err := net.SplitHostPort(c.RemoteAddr().String())
+	if err != nil {
+		return c.RemoteAddr().String()
 	}
+	return host
 }
@@ -2,99 +2,241 @@ package zerocert
 
 import (
 	"context"
-	"crypto"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
-	"github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/registration"
+	"github.com/go-acme/lego/v4/challenge"
+	legotlsalpn01 "github.com/go-acme/lego/v4/challenge/tlsalpn01"
 
 	"github.com/rs/zerocert/internal/cache"
 	"github.com/rs/zerocert/internal/dns01"
 	"github.com/rs/zerocert/internal/glue"
+	"github.com/rs/zerocert/internal/http01"
+	"github.com/rs/zerocert/internal/peer"
+	"github.com/rs/zerocert/internal/tlsalpn01"
 	"github.com/rs/zerocert/internal/tlsutil"
 )
 
 const mTLSDomain = "zerocert"
-const tlsProto = "zerocert"
 
-type Manager struct {
-	// Email is the ACME account's email address.
-	Email string
-
-	// Reg is the ACME account's registration URI.
-	Reg string
-
-	// Key is the ACME account's private key.
-	Key []byte
+// peerTimeout bounds the time spent on each exchange with the members of the
+// cluster, so a member accepting connections without answering them does not
+// block the others.
+const peerTimeout = 30 * time.Second
 
-	// Domain is the domain to obtain a certificate for.
-	Domain string
-
-	// CacheFile is the file to store the certificate and key.
-	CacheFile string
-
-	// TLSConfig serves as a base configuration for the TLS server.
-	TLSConfig *tls.Config
+// Manager obtains, renews and shares a certificate between the members of a
+// cluster. It must be created with New.
+type Manager struct {
+	Config
 
 	dns01Provider dns01.MemoryProvider
 	dns01Server   dns01.Server
 
+	tlsalpn01Provider tlsalpn01.MemoryProvider
+	http01Provider    http01.MemoryProvider
+
 	// Cache is the cache to store the certificate and key.
-	cache cache.Cache
+	cache cache.Layered
+	// fileCache is the local layer of cache.
+	fileCache cache.File
+	// peerCache is the cluster layer of cache.
+	peerCache cache.TLS
+
+	// peers connects to the other members of the cluster.
+	peers peer.Client
+	// lease is the issuance lease this member grants to the cluster.
+	lease peer.Lease
+	// nodeID identifies this member when acquiring issuance leases.
+	nodeID string
+	// refreshing serializes the refreshes of this member, as the lease it
+	// grants itself does not stop it from ordering twice.
+	refreshing chan struct{}
 
 	clientTLSConfig *tls.Config
 	serverTLSConfig *tls.Config
 	caCert          *x509.Certificate
 
-	client *lego.Client
+	acmeCAs []*acmeCA
 
 	glueClient glue.Client
 
+	logger  *slog.Logger
+	metrics Metrics
+
+	zones     []string
+	names     []string
+	keyTypes  []KeyType
+	challenge ChallengeType
+
 	initOnce             sync.Once
+	initErr              error
+	ctx                  context.Context
+	cancel               context.CancelFunc
+	closeMu              sync.Mutex
+	closers              []io.Closer
 	tlsListenerStartOnce sync.Once
 	tlsListenerStarted   chan struct{}
 	dnsListenerStartOnce sync.Once
 	dnsListenerStarted   chan struct{}
 
 	certMu sync.RWMutex
-	cert   *tls.Certificate
+	certs  []*tls.Certificate
+	issuer int
+	// expiring is the certificate OnExpiringSoon was last called for.
+	expiring *x509.Certificate
+	// revoked holds the fingerprints of the revoked certificates.
+	revoked map[string]bool
+	// caRevoked holds the fingerprints of the certificates revoked by the CA,
+	// still served until they are replaced.
+	caRevoked map[string]bool
+	// certsChanged is signaled when the served certificates are replaced.
+	certsChanged chan struct{}
+	// renewNow is signaled when the served certificates must be replaced
+	// without waiting for the next check of Run.
+	renewNow chan struct{}
+	// revocations checks the revocation status of the served certificates.
+	revocations tlsutil.RevocationChecker
+
+	ariMu sync.Mutex
+	ari   map[string]ariWindow
+
+	// accounts holds the registration URIs of the accounts registered with
+	// an external account binding by accountID. See saveAccount.
+	accountsMu sync.Mutex
+	accounts   map[string]string
 }
 
-type legoConfig struct {
-	*Manager
+// New returns a Manager for the given configuration. The Manager must be
+// closed with Close once not used anymore.
+func New(cfg Config) (*Manager, error) {
+	m := &Manager{Config: cfg}
+	if err := m.initialize(); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (c legoConfig) GetEmail() string {
-	return c.Email
-}
+// Close stops the background tasks of the Manager and closes the listeners
+// created with NewTLSListener and NewDNSListener.
+func (m *Manager) Close() error {
+	m.initOnce.Do(func() {
+		// Prevent a closed Manager from being initialized.
+		m.initErr = errors.New("manager closed")
+	})
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	m.closeMu.Lock()
+	closers := m.closers
+	m.closers = nil
+	m.closeMu.Unlock()
 
-func (c legoConfig) GetRegistration() *registration.Resource {
-	return &registration.Resource{
-		URI: c.Reg,
+	var errs []error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// addCloser registers c to be closed by Close.
+func (m *Manager) addCloser(c io.Closer) {
+	m.closeMu.Lock()
+	defer m.closeMu.Unlock()
+	m.closers = append(m.closers, c)
 }
 
-func (c legoConfig) GetPrivateKey() crypto.PrivateKey {
-	privateKey, _ := tlsutil.LoadECPrivateKey(c.Key)
-	return privateKey
+// initialize initializes the manager once and returns the initialization error
+// on every call if it failed.
+func (m *Manager) initialize() error {
+	m.initOnce.Do(func() {
+		if err := m.init(); err != nil {
+			m.initErr = fmt.Errorf("init: %v", err)
+		}
+	})
+	return m.initErr
 }
 
 func (m *Manager) init() error {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.nodeID = rand.Text()
+	m.logger = m.Logger
+	if m.logger == nil {
+		m.logger = slog.Default()
+	}
+	m.metrics = m.Metrics
+	if m.metrics == nil {
+		m.metrics = nopMetrics{}
+	}
 	m.tlsListenerStarted = make(chan struct{})
 	m.dnsListenerStarted = make(chan struct{})
+	m.certsChanged = make(chan struct{}, 1)
+	m.renewNow = make(chan struct{}, 1)
+	m.refreshing = make(chan struct{}, 1)
 
 	privateKey, err := tlsutil.LoadECPrivateKey(m.Key)
 	if err != nil {
 		return fmt.Errorf("loading ACME key: %w", err)
 	}
+
+	m.zones = zones(m.Domain, m.Domains)
+	if len(m.zones) == 0 {
+		return errors.New("no domain")
+	}
+	m.challenge = m.Challenge
+	if m.challenge == "" {
+		m.challenge = ChallengeDNS01
+	}
+	if !m.challenge.valid() {
+		return fmt.Errorf("unsupported challenge type: %s", m.challenge)
+	}
+	if m.DNSProvider != nil && m.challenge != ChallengeDNS01 {
+		return fmt.Errorf("DNSProvider requires the %s challenge", ChallengeDNS01)
+	}
+	m.names = m.Names
+	if len(m.names) == 0 {
+		for _, zone := range m.zones {
+			if m.challenge == ChallengeDNS01 {
+				m.names = append(m.names, "*."+zone)
+			}
+			m.names = append(m.names, zone)
+		}
+	}
+	if m.challenge != ChallengeDNS01 {
+		for _, name := range m.names {
+			if strings.HasPrefix(name, "*.") {
+				return fmt.Errorf("wildcard name %s requires the %s challenge", name, ChallengeDNS01)
+			}
+		}
+	}
+	if !m.builtinDNS() && len(m.PeerIPs) == 0 {
+		// Without glue records, nothing tells the members of a cluster about
+		// each other, so each of them would order its own certificate.
+		m.logger.Warn("no PeerIPs: the certificate is not shared with other members",
+			slog.String("domain", m.names[0]), slog.String("challenge", string(m.challenge)))
+	}
+	if m.builtinDNS() {
+		for _, name := range m.names {
+			if !inZones(name, m.zones) {
+				// The DNS listener would never answer its challenge.
+				return fmt.Errorf("name %s is not in any of the zones %v", name, m.zones)
+			}
+		}
+	}
 	m.caCert, err = tlsutil.GenerateDeterministicCA(privateKey)
 	if err != nil {
 		return fmt.Errorf("generate CA: %w", err)
@@ -113,7 +255,7 @@ func (m *Manager) init() error {
 	m.clientTLSConfig = &tls.Config{
 		Certificates: []tls.Certificate{clientCert},
 		RootCAs:      caCertPool,
-		NextProtos:   []string{tlsProto},
+		NextProtos:   []string{peer.ProtoFetch},
 		ServerName:   mTLSDomain,
 	}
 
@@ -121,7 +263,7 @@ func (m *Manager) init() error {
 		Certificates: []tls.Certificate{serverCert},
 		ClientCAs:    caCertPool,
 		ClientAuth:   tls.RequireAndVerifyClientCert,
-		NextProtos:   []string{tlsProto},
+		NextProtos:   peer.Protos,
 	}
 
 	var serveTLSConfig *tls.Config
@@ -130,11 +272,26 @@ func (m *Manager) init() error {
 	} else {
 		serveTLSConfig = &tls.Config{}
 	}
-	serveTLSConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
-		return m.GetCertificate(), nil
+	serveTLSConfig.GetCertificate = func(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		certs := m.getCertificates()
+		for _, cert := range certs {
+			if chi.SupportsCertificate(cert) == nil {
+				return cert, nil
+			}
+		}
+		if len(certs) > 0 {
+			// Let the handshake fail on the client side.
+			return certs[0], nil
+		}
+		return nil, nil
 	}
 	m.serverTLSConfig = &tls.Config{
 		GetConfigForClient: func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+			if m.challenge == ChallengeTLSALPN01 && slices.Contains(chi.SupportedProtos, legotlsalpn01.ACMETLS1Protocol) {
+				// TLS-ALPN-01 validation connection from the CA. Other
+				// challenge types never query the cluster for a handshake.
+				return m.tlsALPN01Config(chi)
+			}
 			if chi.ServerName == mTLSDomain {
 				// Connections on the mTLS domain are handled by the mTLS TLS
 				// config.
@@ -147,53 +304,102 @@ func (m *Manager) init() error {
 	}
 
 	m.dns01Server = dns01.Server{
-		Zone:     m.Domain,
+		Zones:    m.zones,
 		GetNSIPs: m.glueClient.RetreiveIPs,
 		DistributedChallenger: dns01.IPsChallenger{
 			GetIPs: m.glueClient.RetreiveIPs,
 		},
 		LocalChallenger: &m.dns01Provider,
+		Logger:          m.logger,
+		OnQuery:         m.metrics.DNSQuery,
+		Context:         m.ctx,
 	}
 
-	config := lego.NewConfig(legoConfig{m})
-	config.Certificate.KeyType = certcrypto.RSA2048
-	client, err := lego.NewClient(config)
-	if err != nil {
-		return fmt.Errorf("create ACME client: %v", err)
+	keyType := m.KeyType
+	if keyType == "" {
+		keyType = RSA2048
 	}
-	err = client.Challenge.SetDNS01Provider(&m.dns01Provider)
-	if err != nil {
-		return fmt.Errorf("set DNS01 provider: %v", err)
+	m.keyTypes = []KeyType{keyType}
+	if m.FallbackKeyType != "" && m.FallbackKeyType != keyType {
+		m.keyTypes = append(m.keyTypes, m.FallbackKeyType)
+	}
+	for _, keyType := range m.keyTypes {
+		if !keyType.valid() {
+			return fmt.Errorf("unsupported key type: %s", keyType)
+		}
+	}
+
+	if err := m.loadRevoked(); err != nil {
+		return fmt.Errorf("loading revoked certificates: %w", err)
+	}
+
+	m.issuer = -1
+	for i, ca := range m.cas() {
+		if i > 0 {
+			// An unusable fallback key would only be noticed once the
+			// primary CA fails.
+			if _, err := tlsutil.LoadECPrivateKey(ca.Key); err != nil {
+				return fmt.Errorf("loading ACME key of %s: %w", ca.directory(), err)
+			}
+		}
+		if ca.EAB != nil && ca.Reg == "" && !ca.TermsOfServiceAgreed {
+			return fmt.Errorf("registering with %s requires TermsOfServiceAgreed", ca.directory())
+		}
+		m.acmeCAs = append(m.acmeCAs, &acmeCA{CA: ca})
+	}
+	if err := m.loadAccounts(); err != nil {
+		return fmt.Errorf("loading ACME accounts: %w", err)
+	}
+	// Create the client of the primary CA right away to report
+	// misconfigurations early. Its account is registered when first needed.
+	if _, err := m.acmeClient(0); err != nil {
+		return err
 	}
-	m.client = client
 	return nil
 }
 
 // LoadOrRefresh loads the certificate from the cache if it is not expired.
 // Otherwise, it obtains a new certificate from the ACME server and saves it to
-// the cache.
-func (m *Manager) LoadOrRefresh() (err error) {
-	m.initOnce.Do(func() {
-		if err = m.init(); err != nil {
-			err = fmt.Errorf("init: %v", err)
-		}
-	})
-	if err != nil {
+// the cache. A certificate revoked by the CA is treated as expired and is
+// never loaded back from the caches or the other members of the cluster.
+//
+// It waits for the TLS listener, and the DNS listener with the built-in DNS
+// server, to be created before doing anything, or returns the ctx error if ctx
+// is done first. Concurrent calls wait for each other, so a certificate is
+// obtained only once.
+func (m *Manager) LoadOrRefresh(ctx context.Context) (err error) {
+	if err = m.initialize(); err != nil {
 		return err
 	}
-	<-m.dnsListenerStarted
-	<-m.tlsListenerStarted
+	waits := []chan struct{}{m.tlsListenerStarted}
+	if m.builtinDNS() {
+		waits = append(waits, m.dnsListenerStarted)
+	}
+	for _, started := range waits {
+		select {
+		case <-started:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case m.refreshing <- struct{}{}:
+		defer func() { <-m.refreshing }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 
-	if !m.needsRefresh() {
+	if !m.checkRevocation(ctx) && !m.needsRefresh(ctx) {
 		return
 	}
 
-	if err = m.loadCache(); err != nil {
-		return fmt.Errorf("loadCache: %v", err)
+	if err = m.loadCache(ctx); err != nil {
+		// Caches being unavailable must not prevent obtaining a certificate.
+		m.logger.Warn("loading certificate from cache failed", slog.String("domain", m.names[0]), slog.Any("error", err))
 	}
 
-	if !m.needsRefresh() {
-		if err := m.saveCache(); err != nil {
+	if !m.needsRefresh(ctx) {
+		if err := m.saveCache(ctx); err != nil {
 			// Save back to cache local cache in case we obtained the cert from
 			// a peer.
 			return fmt.Errorf("saveCache: %v", err)
@@ -201,13 +407,37 @@ func (m *Manager) LoadOrRefresh() (err error) {
 		return
 	}
 
-	if err = m.obtain(); err != nil {
+	// Every member reports the expiring certificate, not only the one
+	// obtaining the new one, but only once across retries.
+	old := leaf(m.getCertificates())
+	if old != nil && m.markExpiring(old) {
+		emit(m.OnExpiringSoon, CertificateEvent{Old: old})
+	}
+
+	granted, err := m.acquireLease(ctx)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		// Another member obtained the certificate while we were waiting.
+		if err := m.saveCache(ctx); err != nil {
+			return fmt.Errorf("saveCache: %v", err)
+		}
+		return nil
+	}
+	defer m.releaseLease(ctx)
+
+	err = m.obtain(ctx)
+	m.metrics.RenewalAttempt(err)
+	if err != nil {
+		emit(m.OnRenewalFailed, CertificateEvent{Old: old, Source: SourceACME, Err: err})
 		return fmt.Errorf("obtain: %v", err)
 	}
 
-	if err := m.saveCache(); err != nil {
+	if err := m.saveCache(ctx); err != nil {
 		return fmt.Errorf("saveCache: %v", err)
 	}
+	m.pushCertificates(ctx)
 
 	return nil
 }
@@ -219,39 +449,55 @@ func (m *Manager) LoadOrRefresh() (err error) {
 // It does also automatically handle mTLS connection performed between members
 // of the same cluster to share the certificate and key. Those connections are
 // are hidden to the user of the listener.
-func (m *Manager) NewTLSListener(l net.Listener) net.Listener {
-	m.initOnce.Do(func() {
-		if err := m.init(); err != nil {
-			log.Fatalf("init: %v", err)
-		}
-	})
-	m.tlsListenerStartOnce.Do(func() {
-		close(m.tlsListenerStarted)
-	})
+//
+// It can be called several times, e.g. to listen on both IPv4 and IPv6. The
+// members of the cluster are reached on the port of the first listener.
+//
+// The returned listener is closed by Close.
+func (m *Manager) NewTLSListener(l net.Listener) (net.Listener, error) {
+	if err := m.initialize(); err != nil {
+		return nil, err
+	}
 
 	if l == nil {
-		l, _ = net.Listen("tcp", ":443")
-	}
-	_, port, _ := net.SplitHostPort(l.Addr().String())
-	m.cache = cache.Layered{
-		cache.TLS{
-			Port: port,
-			GetIPs: func(ctx context.Context) ([]net.IP, error) {
-				return m.glueClient.RetreiveIPs(ctx, m.Domain)
-			},
+		var err error
+		if l, err = net.Listen("tcp", ":443"); err != nil {
+			return nil, err
+		}
+	}
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	m.tlsListenerStartOnce.Do(func() {
+		// The peers and caches are read by the background tasks once the
+		// first listener is created, so other listeners, e.g. for another IP
+		// version, leave them alone.
+		m.peers = peer.Client{
+			Port:   port,
+			GetIPs: m.peerIPs,
 			TLSDialer: &tls.Dialer{
 				Config: m.clientTLSConfig,
 			},
-		},
-		cache.File(m.CacheFile),
-	}
-	return &tlsListener{Listener: l, m: m}
+		}
+		m.peerCache = cache.TLS{
+			Client: m.peers,
+			OnFetch: func(ip net.IP, latency time.Duration, err error) {
+				m.metrics.PeerFetch(ip.String(), latency, err)
+			},
+		}
+		m.fileCache = cache.File(m.CacheFile)
+		m.cache = cache.Layered{Logger: m.logger, Caches: []cache.Cache{m.peerCache, m.fileCache}}
+		close(m.tlsListenerStarted)
+	})
+	tl := &tlsListener{Listener: l, m: m}
+	m.addCloser(tl)
+	return tl, nil
 }
 
 type dnsListener struct {
 	net.PacketConn
-	m   *Manager
-	buf []byte
+	m *Manager
 }
 
 type writerTo struct {
@@ -282,84 +528,297 @@ func (l dnsListener) ReadFrom(b []byte) (int, net.Addr, error) {
 // NewDNSListener returns a new DNS listener that wraps the given packet
 // connection. Packets received are intercepted if they are DNS queries for the
 // DNS-01 challenge. In that case, the listener responds with the challenge to
-// the client.
-func (m *Manager) NewDNSListener(pc net.PacketConn) net.PacketConn {
-	m.initOnce.Do(func() {
-		if err := m.init(); err != nil {
-			log.Fatalf("init: %v", err)
+// the client. If pc is nil, a default UDP listener on port 53 is created.
+//
+// The returned listener is closed by Close.
+func (m *Manager) NewDNSListener(pc net.PacketConn) (net.PacketConn, error) {
+	if err := m.initialize(); err != nil {
+		return nil, err
+	}
+	if pc == nil {
+		var err error
+		if pc, err = net.ListenPacket("udp", ":53"); err != nil {
+			return nil, err
 		}
-	})
+	}
 	m.dnsListenerStartOnce.Do(func() {
 		close(m.dnsListenerStarted)
 	})
-	return dnsListener{pc, m, make([]byte, 0, 1500)}
+	dl := dnsListener{pc, m}
+	m.addCloser(dl)
+	return dl, nil
 }
 
-func (m *Manager) loadCache() error {
-	if m.cache == nil {
+func (m *Manager) loadCache(ctx context.Context) error {
+	if m.cache.Caches == nil {
 		return nil
 	}
 
-	cert, err := m.cache.Get(context.Background())
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	certs, from, err := m.cache.GetFrom(ctx)
 	if err != nil {
 		return err
 	}
+	if certs = orderByKeyType(certs, m.keyTypes); len(certs) == 0 {
+		// Keep serving the current certificate if any.
+		return nil
+	}
+	if m.isRevoked(certs) {
+		m.logger.Warn("ignoring revoked certificate from cache", slog.String("domain", m.names[0]))
+		return nil
+	}
+	infos := peer.NewCertInfos(certs)
+	if slices.Equal(fingerprints(infos), fingerprints(peer.NewCertInfos(m.getCertificates()))) {
+		// Already served: keep the renewal windows, only the OCSP staples may
+		// be fresher.
+		m.adoptStaples(infos)
+		return nil
+	}
 
-	log.Println("loaded certificate from cache")
-
-	m.certMu.Lock()
-	defer m.certMu.Unlock()
-	m.cert = cert
+	source := SourceCache
+	if _, ok := from.(cache.TLS); ok {
+		source = SourcePeer
+	}
+	m.logger.Info("loaded certificate from cache", slog.String("domain", m.names[0]), slog.String("source", string(source)))
+	m.setCertificates(certs, source, -1)
 	return nil
 }
 
-func (m *Manager) saveCache() error {
-	if m.cache == nil {
+// saveCache stores the served certificates in the local cache. Use
+// pushCertificates to share them with the cluster.
+func (m *Manager) saveCache(ctx context.Context) error {
+	if m.cache.Caches == nil {
 		return nil
 	}
 
 	m.certMu.RLock()
 	defer m.certMu.RUnlock()
-	return m.cache.Put(context.Background(), m.cert)
+	return m.fileCache.Put(ctx, m.certs)
 }
 
-func (m *Manager) needsRefresh() bool {
-	m.certMu.RLock()
-	defer m.certMu.RUnlock()
-
-	if m.cert == nil {
+func (m *Manager) needsRefresh(ctx context.Context) bool {
+	certs := m.getCertificates()
+	if len(certs) != len(m.keyTypes) {
+		// Some of the configured key types are missing.
 		return true
 	}
-
-	x509Cert, err := x509.ParseCertificate(m.cert.Certificate[0])
-	if err != nil {
+	if m.isRevoked(certs) {
+		// Revoked by the CA, but still served until replaced.
 		return true
 	}
-	return time.Since(x509Cert.NotAfter) > -30*24*time.Hour
+
+	for _, cert := range certs {
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return true
+		}
+		if !tlsutil.CoversNames(x509Cert, m.names) {
+			// The configured names changed since the certificate was obtained.
+			return true
+		}
+		renewAt, err := m.renewAt(ctx, cert)
+		if err != nil || !time.Now().Before(renewAt) {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *Manager) obtain() error {
-	request := certificate.ObtainRequest{
-		Domains: []string{"*." + m.Domain, m.Domain},
-		Bundle:  true,
+// obtain obtains a certificate for each of the configured key types from the
+// first CA that succeeds, in order of preference. The certificates issued by a
+// CA failing for a later key type are kept, and only the missing key types are
+// ordered from the next CA, so they are not issued twice.
+func (m *Manager) obtain(ctx context.Context) error {
+	ariCA := m.ariCA(ctx)
+	replaces := m.replacedCertIDs(ariCA)
+	certs := make([]*tls.Certificate, len(m.keyTypes))
+	issuers := make([]int, len(m.keyTypes))
+	var errs []error
+	for i, ca := range m.acmeCAs {
+		var r []string
+		if i == ariCA {
+			// Certificates can only be replaced at the CA that issued them.
+			r = replaces
+		}
+		if err := m.obtainFrom(i, certs, issuers, r); err != nil {
+			m.logger.Warn("obtain failed", slog.String("ca", ca.directory()), slog.Any("error", err))
+			errs = append(errs, fmt.Errorf("%s: %w", ca.directory(), err))
+			continue
+		}
+		issuer := issuers[0]
+		for _, j := range issuers[1:] {
+			if j != issuer {
+				// The issuer of certificates from several CAs is unknown.
+				issuer = -1
+			}
+		}
+		m.setCertificates(certs, SourceACME, issuer)
+		return nil
 	}
-	res, err := m.client.Certificate.Obtain(request)
+	return errors.Join(errs...)
+}
+
+// obtainFrom obtains the certificates missing in certs from the i-th CA, in
+// key type order, and sets their issuers to i. replaces holds the ARI
+// identifiers of the certificates they replace, if any.
+func (m *Manager) obtainFrom(i int, certs []*tls.Certificate, issuers []int, replaces []string) error {
+	client, err := m.accountClient(i)
 	if err != nil {
 		return err
 	}
-	cert, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
-	if err != nil {
-		return err
+	for j, keyType := range m.keyTypes {
+		if certs[j] != nil {
+			continue
+		}
+		privateKey, err := certcrypto.GeneratePrivateKey(certcrypto.KeyType(keyType))
+		if err != nil {
+			return fmt.Errorf("generate %s key: %w", keyType, err)
+		}
+		request := certificate.ObtainRequest{
+			Domains:        m.names,
+			Bundle:         true,
+			PrivateKey:     privateKey,
+			PreferredChain: m.acmeCAs[i].PreferredChain,
+			Profile:        m.acmeCAs[i].Profile,
+		}
+		if j < len(replaces) {
+			request.ReplacesCertID = replaces[j]
+		}
+		res, err := client.Certificate.Obtain(request)
+		if err != nil {
+			return fmt.Errorf("%s: %w", keyType, err)
+		}
+		cert, err := tls.X509KeyPair(res.Certificate, res.PrivateKey)
+		if err != nil {
+			return err
+		}
+		certs[j], issuers[j] = &cert, i
+	}
+	return nil
+}
+
+// peerIPs returns the configured PeerIPs or, with the built-in DNS server, the
+// glue IP addresses of all the zones, without duplicates.
+func (m *Manager) peerIPs(ctx context.Context) ([]net.IP, error) {
+	if len(m.PeerIPs) > 0 {
+		return m.PeerIPs, nil
+	}
+	if !m.builtinDNS() {
+		// Glue records only point to the cluster if it serves the zones.
+		return nil, nil
+	}
+	var ips []net.IP
+	var errs []error
+	for _, zone := range m.zones {
+		zoneIPs, err := m.glueClient.RetreiveIPs(ctx, zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", zone, err))
+			continue
+		}
+		for _, ip := range zoneIPs {
+			if !slices.ContainsFunc(ips, ip.Equal) {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	if len(ips) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return ips, nil
+}
+
+// builtinDNS returns true if the DNS-01 challenges are served by the DNS
+// listener.
+func (m *Manager) builtinDNS() bool {
+	return m.challenge == ChallengeDNS01 && m.DNSProvider == nil
+}
+
+// dnsChallengeProvider returns the provider presenting the DNS-01 challenges.
+func (m *Manager) dnsChallengeProvider() challenge.Provider {
+	if m.DNSProvider != nil {
+		return m.DNSProvider
+	}
+	return &m.dns01Provider
+}
+
+// zones returns the normalized list of zones made of domain and domains,
+// without duplicates.
+func zones(domain string, domains []string) []string {
+	var zones []string
+	for _, zone := range append([]string{domain}, domains...) {
+		zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+		if zone != "" && !slices.Contains(zones, zone) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// inZones returns true if name is one of zones or a subdomain of one of them.
+func inZones(name string, zones []string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	return slices.ContainsFunc(zones, func(zone string) bool {
+		return name == zone || strings.HasSuffix(name, "."+zone)
+	})
+}
+
+// GetCertificate returns the certificate of the configured KeyType or nil if
+// no certificate has been obtained yet.
+func (c *Manager) GetCertificate() *tls.Certificate {
+	c.certMu.RLock()
+	defer c.certMu.RUnlock()
+	if len(c.certs) == 0 {
+		return nil
 	}
+	return c.certs[0]
+}
 
+// setCertificates replaces the served certificates with certs coming from
+// source and calls the corresponding hook. issuer is the index of the CA that
+// issued certs or -1 if unknown.
+func (m *Manager) setCertificates(certs []*tls.Certificate, source CertificateSource, issuer int) {
+	m.certMu.Lock()
+	old := leaf(m.certs)
+	m.certs = certs
+	m.issuer = issuer
+	m.certMu.Unlock()
+	m.resetRenewalWindows()
+	m.reportExpiry()
+	select {
+	case m.certsChanged <- struct{}{}:
+	default:
+	}
+
+	e := CertificateEvent{Old: old, New: leaf(certs), Source: source}
+	if issuer >= 0 {
+		e.Issuer = m.acmeCAs[issuer].directory()
+	}
+	switch source {
+	case SourceACME:
+		emit(m.OnCertificateObtained, e)
+	case SourcePeer:
+		if e.New != nil && (old == nil || !old.Equal(e.New)) {
+			emit(m.OnCertificateLoadedFromPeer, e)
+		}
+	}
+}
+
+// markExpiring records that OnExpiringSoon is called for cert and returns
+// false if it was already called for it.
+func (m *Manager) markExpiring(cert *x509.Certificate) bool {
 	m.certMu.Lock()
 	defer m.certMu.Unlock()
-	m.cert = &cert
-	return nil
+	if m.expiring != nil && m.expiring.Equal(cert) {
+		return false
+	}
+	m.expiring = cert
+	return true
 }
 
-func (c *Manager) GetCertificate() *tls.Certificate {
+// getCertificates returns the certificates of all the configured key types.
+func (c *Manager) getCertificates() []*tls.Certificate {
 	c.certMu.RLock()
 	defer c.certMu.RUnlock()
-	return c.cert
+	return c.certs
 }
@@ -0,0 +1,49 @@
+package zerocert
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// Metrics receives measurements from the Manager and its listeners.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// CertificateExpiry reports the expiration date of the certificate served
+	// for the given key type each time it changes.
+	CertificateExpiry(keyType KeyType, notAfter time.Time)
+
+	// RenewalAttempt reports the outcome of an attempt to obtain a new
+	// certificate from the CA, err being nil on success.
+	RenewalAttempt(err error)
+
+	// PeerFetch reports the latency and outcome of a certificate fetch from a
+	// peer.
+	PeerFetch(peer string, latency time.Duration, err error)
+
+	// DNSQuery reports a DNS-01 challenge query answered with rcode, e.g.
+	// "Success" or "NameError".
+	DNSQuery(rcode string)
+
+	// Handshake reports a TLS handshake accepted by the TLS listener, mTLS
+	// being true for connections between members of the cluster.
+	Handshake(mTLS bool, err error)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) CertificateExpiry(KeyType, time.Time)   {}
+func (nopMetrics) RenewalAttempt(error)                   {}
+func (nopMetrics) PeerFetch(string, time.Duration, error) {}
+func (nopMetrics) DNSQuery(string)                        {}
+func (nopMetrics) Handshake(bool, error)                  {}
+
+// reportExpiry reports the expiration date of the current certificates.
+func (m *Manager) reportExpiry() {
+	for _, cert := range m.getCertificates() {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			continue
+		}
+		m.metrics.CertificateExpiry(keyTypeOf(cert), leaf.NotAfter)
+	}
+}
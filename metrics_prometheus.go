@@ -0,0 +1,144 @@
+package zerocert
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusMetrics is a Metrics implementation that exposes the measurements
+// in the Prometheus text exposition format when served over HTTP. The zero
+// value is ready to use.
+type PrometheusMetrics struct {
+	mu               sync.Mutex
+	expiry           map[KeyType]time.Time
+	renewals         map[string]uint64
+	peerFetches      map[[2]string]uint64
+	peerFetchSeconds map[string]float64
+	peerFetchCount   map[string]uint64
+	dnsQueries       map[string]uint64
+	handshakes       map[[2]string]uint64
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+func (p *PrometheusMetrics) CertificateExpiry(keyType KeyType, notAfter time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.expiry == nil {
+		p.expiry = map[KeyType]time.Time{}
+	}
+	p.expiry[keyType] = notAfter
+}
+
+func (p *PrometheusMetrics) RenewalAttempt(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.renewals == nil {
+		p.renewals = map[string]uint64{}
+	}
+	p.renewals[outcome(err)]++
+}
+
+func (p *PrometheusMetrics) PeerFetch(peer string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peerFetches == nil {
+		p.peerFetches = map[[2]string]uint64{}
+		p.peerFetchSeconds = map[string]float64{}
+		p.peerFetchCount = map[string]uint64{}
+	}
+	p.peerFetches[[2]string{peer, outcome(err)}]++
+	p.peerFetchSeconds[peer] += latency.Seconds()
+	p.peerFetchCount[peer]++
+}
+
+func (p *PrometheusMetrics) DNSQuery(rcode string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dnsQueries == nil {
+		p.dnsQueries = map[string]uint64{}
+	}
+	p.dnsQueries[rcode]++
+}
+
+func (p *PrometheusMetrics) Handshake(mTLS bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.handshakes == nil {
+		p.handshakes = map[[2]string]uint64{}
+	}
+	kind := "public"
+	if mTLS {
+		kind = "mtls"
+	}
+	p.handshakes[[2]string{kind, outcome(err)}]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w.
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	header := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("zerocert_certificate_expiry_timestamp_seconds", "gauge", "Expiration date of the served certificate.")
+	for _, keyType := range slices.Sorted(maps.Keys(p.expiry)) {
+		fmt.Fprintf(&b, "zerocert_certificate_expiry_timestamp_seconds{key_type=%q} %d\n", keyType, p.expiry[keyType].Unix())
+	}
+
+	header("zerocert_renewals_total", "counter", "Attempts to obtain a certificate from the CA by outcome.")
+	for _, o := range slices.Sorted(maps.Keys(p.renewals)) {
+		fmt.Fprintf(&b, "zerocert_renewals_total{outcome=%q} %d\n", o, p.renewals[o])
+	}
+
+	header("zerocert_peer_fetches_total", "counter", "Certificate fetches from peers by peer and outcome.")
+	for _, k := range slices.SortedFunc(maps.Keys(p.peerFetches), compareKeys) {
+		fmt.Fprintf(&b, "zerocert_peer_fetches_total{peer=%q,outcome=%q} %d\n", k[0], k[1], p.peerFetches[k])
+	}
+
+	header("zerocert_peer_fetch_duration_seconds", "summary", "Latency of certificate fetches from peers.")
+	for _, peer := range slices.Sorted(maps.Keys(p.peerFetchCount)) {
+		fmt.Fprintf(&b, "zerocert_peer_fetch_duration_seconds_sum{peer=%q} %g\n", peer, p.peerFetchSeconds[peer])
+		fmt.Fprintf(&b, "zerocert_peer_fetch_duration_seconds_count{peer=%q} %d\n", peer, p.peerFetchCount[peer])
+	}
+
+	header("zerocert_dns_queries_total", "counter", "DNS-01 challenge queries by response code.")
+	for _, rcode := range slices.Sorted(maps.Keys(p.dnsQueries)) {
+		fmt.Fprintf(&b, "zerocert_dns_queries_total{rcode=%q} %d\n", rcode, p.dnsQueries[rcode])
+	}
+
+	header("zerocert_handshakes_total", "counter", "TLS handshakes by type (mtls or public) and outcome.")
+	for _, k := range slices.SortedFunc(maps.Keys(p.handshakes), compareKeys) {
+		fmt.Fprintf(&b, "zerocert_handshakes_total{type=%q,outcome=%q} %d\n", k[0], k[1], p.handshakes[k])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func compareKeys(a, b [2]string) int {
+	if c := strings.Compare(a[0], b[0]); c != 0 {
+		return c
+	}
+	return strings.Compare(a[1], b[1])
+}
@@ -0,0 +1,40 @@
+package zerocert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	var p PrometheusMetrics
+	p.CertificateExpiry(EC256, time.Unix(1700000000, 0))
+	p.RenewalAttempt(nil)
+	p.RenewalAttempt(errors.New("rate limited"))
+	p.PeerFetch("192.0.2.1", 500*time.Millisecond, nil)
+	p.PeerFetch("192.0.2.1", 1500*time.Millisecond, errors.New("timeout"))
+	p.DNSQuery("Success")
+	p.Handshake(true, nil)
+	p.Handshake(false, nil)
+
+	var b strings.Builder
+	if _, err := p.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`zerocert_certificate_expiry_timestamp_seconds{key_type="P256"} 1700000000`,
+		`zerocert_renewals_total{outcome="error"} 1`,
+		`zerocert_renewals_total{outcome="success"} 1`,
+		`zerocert_peer_fetches_total{peer="192.0.2.1",outcome="error"} 1`,
+		`zerocert_peer_fetch_duration_seconds_sum{peer="192.0.2.1"} 2`,
+		`zerocert_peer_fetch_duration_seconds_count{peer="192.0.2.1"} 2`,
+		`zerocert_dns_queries_total{rcode="Success"} 1`,
+		`zerocert_handshakes_total{type="mtls",outcome="success"} 1`,
+		`zerocert_handshakes_total{type="public",outcome="success"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, b.String())
+		}
+	}
+}
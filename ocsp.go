@@ -0,0 +1,239 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/rs/zerocert/internal/peer"
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+const (
+	// stapleRetryDelay is the delay before trying again to fetch an OCSP
+	// response after a failure.
+	stapleRetryDelay = 10 * time.Minute
+
+	// stapleSpread bounds the random delay before stapling new certificates,
+	// so the members of the cluster don't query the responder at the same
+	// moment and the first one to get a staple shares it with the others.
+	stapleSpread = 2 * time.Minute
+)
+
+// stapleLoop keeps the OCSP staples of the served certificates fresh until ctx
+// is done. Staples are refreshed halfway through their validity and pushed to
+// the other members of the cluster, so only the first member to refresh
+// queries the responder.
+func (m *Manager) stapleLoop(ctx context.Context) {
+	select {
+	case <-m.tlsListenerStarted:
+	case <-ctx.Done():
+		return
+	}
+	delay := rand.N(stapleSpread)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.certsChanged:
+			delay = rand.N(stapleSpread)
+			continue
+		case <-time.After(delay):
+		}
+		delay = m.checkInterval()
+		if next := m.refreshStaples(ctx); !next.IsZero() {
+			delay = min(max(time.Until(next), minRetryDelay), delay)
+		}
+		delay = jitter(delay)
+	}
+}
+
+// refreshStaples fetches the OCSP responses of the served certificates whose
+// staple is missing or due for refresh, and returns the time of the next
+// refresh, or zero if none of the certificates supports OCSP. Fresher staples
+// served by the other members of the cluster are adopted first, so the
+// responder is only queried if none of them refreshed it already.
+func (m *Manager) refreshStaples(ctx context.Context) time.Time {
+	if slices.ContainsFunc(m.getCertificates(), stapleDue) {
+		m.pullStaples(ctx)
+	}
+
+	certs := m.getCertificates()
+	staples := make([][]byte, len(certs))
+	var next time.Time
+	var updated bool
+	for i, cert := range certs {
+		if refreshAt := stapleRefreshTime(cert); !refreshAt.IsZero() && time.Now().Before(refreshAt) {
+			next = earliest(next, refreshAt)
+			continue
+		}
+
+		raw, resp, err := tlsutil.FetchOCSP(ctx, nil, cert)
+		if errors.Is(err, tlsutil.ErrNoOCSPServer) {
+			continue
+		}
+		if err == nil && resp.Status == ocsp.Revoked {
+			m.handleRevocation(ctx, certs)
+			select {
+			case m.renewNow <- struct{}{}:
+			default:
+			}
+			return time.Time{}
+		}
+		if err == nil && resp.Status != ocsp.Good {
+			err = errors.New("certificate status is unknown")
+		}
+		if err != nil {
+			m.logger.Warn("fetching OCSP response failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+			next = earliest(next, time.Now().Add(stapleRetryDelay))
+			continue
+		}
+		staples[i] = raw
+		updated = true
+		next = earliest(next, stapleRefreshAt(resp))
+	}
+
+	if updated && m.setStaples(certs, staples) {
+		m.logger.Debug("refreshed OCSP staple", slog.String("domain", m.names[0]))
+		if err := m.saveCache(ctx); err != nil {
+			m.logger.Warn("saving OCSP staple failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+		}
+		m.pushCertificates(ctx)
+	}
+	return next
+}
+
+// pullStaples adopts the fresher OCSP staples of the served certificates held
+// by the other members of the cluster. Only the staples are transferred, along
+// with the fingerprints of their leaves.
+func (m *Manager) pullStaples(ctx context.Context) {
+	if m.cache.Caches == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	ips, err := m.peers.IPs(ctx)
+	if err != nil {
+		return
+	}
+	var adopted bool
+	for _, ip := range ips {
+		infos, err := m.peers.Info(ctx, ip)
+		if err != nil {
+			continue
+		}
+		if m.adoptStaples(infos) {
+			adopted = true
+		}
+	}
+	if adopted {
+		m.logger.Debug("adopted OCSP staple from peer", slog.String("domain", m.names[0]))
+		if err := m.saveCache(ctx); err != nil {
+			m.logger.Warn("saving OCSP staple failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+		}
+	}
+}
+
+// setStaples attaches the non-nil staples to the served certificates if they
+// are still certs. It returns false if the served certificates changed.
+func (m *Manager) setStaples(certs []*tls.Certificate, staples [][]byte) bool {
+	m.certMu.Lock()
+	defer m.certMu.Unlock()
+	if len(m.certs) != len(certs) {
+		return false
+	}
+	stapled := make([]*tls.Certificate, len(certs))
+	for i, cert := range m.certs {
+		if cert != certs[i] {
+			return false
+		}
+		stapled[i] = cert
+		if staples[i] != nil {
+			// Certificates are shared with running handshakes, so they are
+			// copied rather than modified.
+			c := *cert
+			c.OCSPStaple = staples[i]
+			stapled[i] = &c
+		}
+	}
+	m.certs = stapled
+	return true
+}
+
+// adoptStaples attaches the staples of infos to the served certificates with
+// the same leaf fingerprints if they are fresher. It returns true if any
+// staple was adopted.
+func (m *Manager) adoptStaples(infos []peer.CertInfo) bool {
+	byFingerprint := make(map[string][]byte, len(infos))
+	for _, info := range infos {
+		if len(info.OCSPStaple) > 0 {
+			byFingerprint[info.Fingerprint] = info.OCSPStaple
+		}
+	}
+	current := m.getCertificates()
+	staples := make([][]byte, len(current))
+	var adopted bool
+	for i, cert := range current {
+		staple := byFingerprint[peer.Fingerprint(cert.Certificate[0])]
+		if staple == nil {
+			continue
+		}
+		// The staple is verified against the chain of the served certificate.
+		c := *cert
+		c.OCSPStaple = staple
+		resp, err := tlsutil.ParseOCSPStaple(&c)
+		if err != nil || resp == nil || resp.Status != ocsp.Good {
+			continue
+		}
+		if cur, err := tlsutil.ParseOCSPStaple(cert); err == nil && cur != nil && !resp.ThisUpdate.After(cur.ThisUpdate) {
+			continue
+		}
+		staples[i] = staple
+		adopted = true
+	}
+	return adopted && m.setStaples(current, staples)
+}
+
+// stapleRefreshTime returns the time at which the staple of cert must be
+// refreshed, or zero if it has none.
+func stapleRefreshTime(cert *tls.Certificate) time.Time {
+	if resp, err := tlsutil.ParseOCSPStaple(cert); err == nil && resp != nil {
+		return stapleRefreshAt(resp)
+	}
+	return time.Time{}
+}
+
+// stapleDue returns true if cert supports OCSP and its staple is missing or
+// due for refresh.
+func stapleDue(cert *tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || len(leaf.OCSPServer) == 0 {
+		return false
+	}
+	refreshAt := stapleRefreshTime(cert)
+	return refreshAt.IsZero() || !time.Now().Before(refreshAt)
+}
+
+// stapleRefreshAt returns the time at which resp must be refreshed, halfway
+// through its validity.
+func stapleRefreshAt(resp *ocsp.Response) time.Time {
+	if resp.NextUpdate.IsZero() {
+		return resp.ThisUpdate.Add(defaultCheckInterval)
+	}
+	return resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+}
+
+// earliest returns the earliest of a and b, ignoring zero times.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,89 @@
+package zerocert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+// servedStaple returns the OCSP staple of the certificate m serves to a TLS
+// 1.3 client.
+func servedStaple(t *testing.T, m *Manager) []byte {
+	t.Helper()
+	chi := &tls.ClientHelloInfo{
+		ServerName:        "example.com",
+		SupportedVersions: []uint16{tls.VersionTLS13},
+		CipherSuites:      []uint16{tls.TLS_AES_128_GCM_SHA256},
+		SignatureSchemes:  []tls.SignatureScheme{tls.PSSWithSHA256, tls.PKCS1WithSHA256},
+		SupportedCurves:   []tls.CurveID{tls.X25519},
+	}
+	config, err := m.serverTLSConfig.GetConfigForClient(chi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := config.GetCertificate(chi)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	return cert.OCSPStaple
+}
+
+func TestManager_refreshStaples(t *testing.T) {
+	acme := (&testACMEServer{ocsp: true}).start(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	ctx := context.Background()
+	if err := members[0].obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	members[0].pushCertificates(ctx)
+
+	// The staple is fetched from the responder, served and pushed to the
+	// other members.
+	next := members[0].refreshStaples(ctx)
+	if got := acme.ocspStats(); got != 1 {
+		t.Fatalf("OCSP requests = %d, want 1", got)
+	}
+	staple := servedStaple(t, members[0])
+	if staple == nil {
+		t.Fatal("no OCSP staple served")
+	}
+	// The responses are valid for 4 days and refreshed halfway.
+	if until := time.Until(next); until < 47*time.Hour || until > 48*time.Hour {
+		t.Errorf("refreshStaples() next refresh in %v, want in 2 days", until)
+	}
+	if !bytes.Equal(servedStaple(t, members[1]), staple) {
+		t.Error("OCSP staple not pushed to the peer")
+	}
+
+	// A member missing the staple pulls it from its peers instead of
+	// querying the responder.
+	unstapled := *members[1].getCertificates()[0]
+	unstapled.OCSPStaple = nil
+	members[1].setCertificates([]*tls.Certificate{&unstapled}, SourcePeer, -1)
+	members[1].refreshStaples(ctx)
+	if got := acme.ocspStats(); got != 1 {
+		t.Errorf("OCSP requests = %d, want 1", got)
+	}
+	if !bytes.Equal(servedStaple(t, members[1]), staple) {
+		t.Error("OCSP staple not pulled from the peer")
+	}
+
+	// Staples fetched for replaced certificates are dropped.
+	stapled := members[0].getCertificates()
+	if err := members[0].obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	if members[0].setStaples(stapled, [][]byte{staple}) {
+		t.Error("setStaples() = true after the certificates changed, want false")
+	}
+	if servedStaple(t, members[0]) != nil {
+		t.Error("OCSP staple of the replaced certificate served")
+	}
+}
@@ -0,0 +1,78 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rs/zerocert/internal/peer"
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+// pushCertificates pushes the served certificates to the members of the
+// cluster so they serve them without waiting for their next refresh. Failures
+// are only logged as members still pull the certificates on their own.
+func (m *Manager) pushCertificates(ctx context.Context) {
+	if m.cache.Caches == nil {
+		return
+	}
+	certs := m.getCertificates()
+	if len(certs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	if err := m.peerCache.Put(ctx, certs); err != nil {
+		m.logger.Warn("pushing certificate to peers failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+	}
+}
+
+// acceptPushed serves certs pushed by a member of the cluster and stores them
+// in the local cache if they are valid for the configured names and were
+// issued after the served ones.
+func (m *Manager) acceptPushed(ctx context.Context, certs []*tls.Certificate) error {
+	if certs = orderByKeyType(certs, m.keyTypes); len(certs) != len(m.keyTypes) {
+		return errors.New("missing key types")
+	}
+	now := time.Now()
+	for _, cert := range certs {
+		if err := tlsutil.ValidateKeyPair(cert, m.names, now); err != nil {
+			return err
+		}
+	}
+
+	if m.isRevoked(certs) {
+		return errors.New("revoked certificate")
+	}
+
+	pushed := leaf(certs)
+	served := m.getCertificates()
+	if current := leaf(served); current != nil {
+		if current.Equal(pushed) {
+			// Already served, e.g. pushed back to the member that obtained it,
+			// but the OCSP staples may be fresher.
+			if m.adoptStaples(peer.NewCertInfos(certs)) {
+				if err := m.saveCache(ctx); err != nil {
+					m.logger.Warn("saving pushed OCSP staple failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+				}
+			}
+			return nil
+		}
+		// Compare issuance dates as the new certificate may have a shorter
+		// lifetime, e.g. when obtained with another profile or from another CA.
+		// Any certificate replaces one revoked by the CA.
+		if !pushed.NotBefore.After(current.NotBefore) && !m.isRevoked(served) {
+			return fmt.Errorf("not newer than the served certificate")
+		}
+	}
+
+	m.logger.Info("received certificate from peer", slog.String("domain", m.names[0]))
+	m.setCertificates(certs, SourcePeer, -1)
+	if err := m.saveCache(ctx); err != nil {
+		m.logger.Warn("saving pushed certificate failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/rs/zerocert/internal/peer"
+)
+
+func TestManager_acceptPushed(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := testKeyPair(t, ecKey, now.Add(-10*day), 90*day)
+	revoked := testKeyPair(t, ecKey, now.Add(-day), 90*day)
+
+	acme := newTestACMEServer(t)
+	tests := []struct {
+		name     string
+		pushed   *tls.Certificate
+		accepted bool
+		wantErr  bool
+	}{
+		{"newer", testKeyPair(t, ecKey, now.Add(-day), 90*day), true, false},
+		{"newer with shorter lifetime", testKeyPair(t, ecKey, now.Add(-time.Hour), 6*day), true, false},
+		{"older with longer lifetime", testKeyPair(t, ecKey, now.Add(-20*day), 100*day), false, true},
+		{"same", current, false, false},
+		{"expired", testKeyPair(t, ecKey, now.Add(-100*day), 90*day), false, true},
+		{"missing key type", testKeyPair(t, rsaKey, now.Add(-day), 90*day), false, true},
+		{"revoked", revoked, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(Config{
+				Key:          []byte(testKey),
+				Domain:       "example.com",
+				DirectoryURL: acme.directory(),
+				CARoots:      acme.roots,
+				KeyType:      EC256,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+			m.setCertificates([]*tls.Certificate{current}, SourceCache, -1)
+			m.revoked = map[string]bool{peer.Fingerprint(revoked.Certificate[0]): true}
+
+			err = m.acceptPushed(context.Background(), []*tls.Certificate{tt.pushed})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("acceptPushed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := current
+			if tt.accepted {
+				want = tt.pushed
+			}
+			if got := m.GetCertificate(); got != want {
+				t.Errorf("served the certificate issued at %v, want %v", leaf([]*tls.Certificate{got}).NotBefore, leaf([]*tls.Certificate{want}).NotBefore)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package zerocert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+// Account is an ACME account as expected by the Email, Reg and Key fields of
+// Config.
+type Account struct {
+	Email string
+	Reg   string
+	Key   []byte
+}
+
+func (a *Account) GetEmail() string {
+	return a.Email
+}
+
+func (a *Account) GetRegistration() *registration.Resource {
+	if a.Reg == "" {
+		return nil
+	}
+	return &registration.Resource{URI: a.Reg}
+}
+
+func (a *Account) GetPrivateKey() crypto.PrivateKey {
+	privateKey, err := tlsutil.LoadECPrivateKey(a.Key)
+	if err != nil {
+		// Not a typed nil, which lego would take for a key.
+		return nil
+	}
+	return privateKey
+}
+
+// RegisterOption configures Register.
+type RegisterOption func(*registerOptions)
+
+type registerOptions struct {
+	roots *x509.CertPool
+	eab   *ExternalAccountBinding
+}
+
+// WithCARoots sets the root certificates trusted to connect to the CA, like
+// Config.CARoots. The system roots are used by default.
+func WithCARoots(roots *x509.CertPool) RegisterOption {
+	return func(o *registerOptions) {
+		o.roots = roots
+	}
+}
+
+// WithEAB registers the account with the external account binding required by
+// some CAs.
+func WithEAB(eab ExternalAccountBinding) RegisterOption {
+	return func(o *registerOptions) {
+		o.eab = &eab
+	}
+}
+
+// ExternalAccountBinding holds the credentials provided by CAs that require
+// binding ACME accounts to an account in their own system (RFC 8555 section
+// 7.3.4), like ZeroSSL or Google Trust Services.
+type ExternalAccountBinding struct {
+	// KeyID is the key identifier provided by the CA.
+	KeyID string
+
+	// HMACKey is the base64url encoded MAC key provided by the CA.
+	HMACKey string
+}
+
+// Register generates a new EC account key and registers it with the CA at
+// directory. An empty directory selects the Let's Encrypt production
+// directory. tosAccepted tells the CA that the terms of service have been
+// agreed to, which most CAs require.
+func Register(ctx context.Context, email, directory string, tosAccepted bool, opts ...RegisterOption) (*Account, error) {
+	var o registerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key: %w", err)
+	}
+	account := &Account{
+		Email: email,
+		Key:   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}),
+	}
+
+	config := newLegoConfig(account, directory, o.roots)
+	config.HTTPClient.Transport = ctxTransport{ctx, config.HTTPClient.Transport}
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("create ACME client: %w", err)
+	}
+	var reg *registration.Resource
+	if o.eab != nil {
+		reg, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: tosAccepted,
+			Kid:                  o.eab.KeyID,
+			HmacEncoded:          o.eab.HMACKey,
+		})
+	} else {
+		reg, err = client.Registration.Register(registration.RegisterOptions{
+			TermsOfServiceAgreed: tosAccepted,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("register: %w", err)
+	}
+	account.Reg = reg.URI
+	return account, nil
+}
+
+// ctxTransport binds the requests it sends to ctx.
+type ctxTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t ctxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
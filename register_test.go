@@ -0,0 +1,64 @@
+package zerocert
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+func TestRegister(t *testing.T) {
+	acme := newTestACMEServer(t)
+	ctx := context.Background()
+
+	account, err := Register(ctx, "test@example.com", acme.directory(), true, WithCARoots(acme.roots))
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if want := acme.URL + "/account/1"; account.Reg != want {
+		t.Errorf("Reg = %q, want %q", account.Reg, want)
+	}
+	if account.Email != "test@example.com" {
+		t.Errorf("Email = %q, want test@example.com", account.Email)
+	}
+	if _, err := tlsutil.LoadECPrivateKey(account.Key); err != nil {
+		t.Errorf("Key is not an EC private key: %v", err)
+	}
+	regs := acme.registrations()
+	if len(regs) != 1 {
+		t.Fatalf("CA got %d registrations, want 1", len(regs))
+	}
+	var tosAgreed bool
+	if err := json.Unmarshal(regs[0]["termsOfServiceAgreed"], &tosAgreed); err != nil || !tosAgreed {
+		t.Errorf("termsOfServiceAgreed = %s, want true", regs[0]["termsOfServiceAgreed"])
+	}
+
+	// The CA can't be reached without trusting its roots.
+	if _, err := Register(ctx, "test@example.com", acme.directory(), true); err == nil {
+		t.Error("Register() without the CA roots error = nil")
+	}
+}
+
+func TestRegister_EAB(t *testing.T) {
+	acme := newTestACMEServer(t)
+	eab := ExternalAccountBinding{
+		KeyID:   "kid-1",
+		HMACKey: base64.RawURLEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	account, err := Register(context.Background(), "test@example.com", acme.directory(), true, WithCARoots(acme.roots), WithEAB(eab))
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if want := acme.URL + "/account/1"; account.Reg != want {
+		t.Errorf("Reg = %q, want %q", account.Reg, want)
+	}
+	regs := acme.registrations()
+	if len(regs) != 1 {
+		t.Fatalf("CA got %d registrations, want 1", len(regs))
+	}
+	if kid := eabKeyID(t, regs[0]); kid != eab.KeyID {
+		t.Errorf("externalAccountBinding key ID = %q, want %q", kid, eab.KeyID)
+	}
+}
@@ -0,0 +1,355 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+const (
+	defaultCheckInterval = 24 * time.Hour
+	minRetryDelay        = time.Minute
+	defaultARIRetryAfter = 6 * time.Hour
+	// ariTimeout bounds the time spent fetching a renewal window.
+	ariTimeout = 30 * time.Second
+)
+
+// RenewalPolicy returns the time at which a certificate valid from notBefore
+// to notAfter must be renewed.
+type RenewalPolicy func(notBefore, notAfter time.Time) time.Time
+
+// RenewAtLifetimeFraction returns a policy renewing certificates once the
+// fraction f of their lifetime is elapsed, e.g. 2.0/3 renews a 90 days
+// certificate 30 days before it expires and a 6 days certificate 2 days
+// before it expires.
+func RenewAtLifetimeFraction(f float64) RenewalPolicy {
+	return func(notBefore, notAfter time.Time) time.Time {
+		return notBefore.Add(time.Duration(f * float64(notAfter.Sub(notBefore))))
+	}
+}
+
+// RenewBeforeExpiry returns a policy renewing certificates d before they
+// expire. Certificates with a lifetime shorter than d, like the ones of short
+// lived profiles, are renewed at two thirds of their lifetime instead.
+func RenewBeforeExpiry(d time.Duration) RenewalPolicy {
+	return func(notBefore, notAfter time.Time) time.Time {
+		if notAfter.Sub(notBefore) <= d {
+			// Renewing d before expiry would renew right after issuance.
+			return defaultRenewalPolicy(notBefore, notAfter)
+		}
+		return notAfter.Add(-d)
+	}
+}
+
+var defaultRenewalPolicy = RenewAtLifetimeFraction(2.0 / 3)
+
+var errUnknownCertificate = errors.New("certificate unknown to the CA")
+
+// Run calls LoadOrRefresh immediately and then every CheckInterval until ctx
+// is cancelled. A random jitter is added to each check so peers of the same
+// cluster don't renew at the same moment, and failed attempts are retried with
+// an exponential backoff. In the background, the served certificate is
+// reconciled with the other members of the cluster every SyncInterval, and
+// its OCSP staple is kept fresh.
+//
+// Run returns nil once ctx is cancelled or the Manager is closed, or the error
+// of the Manager initialization if it failed.
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.initialize(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(m.ctx, cancel)
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		m.syncLoop(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		m.stapleLoop(ctx)
+	}()
+
+	var failures int
+	for {
+		var delay time.Duration
+		if err := m.LoadOrRefresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			delay = retryDelay(failures, m.checkInterval())
+			m.logger.Error("renewal failed",
+				slog.String("domain", m.names[0]),
+				slog.Int("attempt", failures),
+				slog.Duration("retry_in", delay),
+				slog.Any("error", err))
+		} else {
+			failures = 0
+			delay = m.checkInterval()
+			if next := m.nextRenewalCheck(ctx); !next.IsZero() && time.Until(next) < delay {
+				// The CA asked to check back or to renew earlier.
+				delay = max(time.Until(next), minRetryDelay)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-m.renewNow:
+		case <-time.After(jitter(delay)):
+		}
+	}
+}
+
+func (m *Manager) checkInterval() time.Duration {
+	if m.CheckInterval > 0 {
+		return m.CheckInterval
+	}
+	return defaultCheckInterval
+}
+
+// retryDelay returns the delay before the next attempt after the given number
+// of consecutive failures, doubling from minRetryDelay up to max.
+func retryDelay(failures int, max time.Duration) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
+
+// jitter returns d randomly shifted by up to 10% in either direction.
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d / 10)
+	if spread <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*spread)-spread)
+}
+
+// renewAt returns the time at which cert must be renewed. The RenewalPolicy
+// is applied to the validity period of the chain, which ends with the earliest
+// expiration of the leaf and its intermediates. If the CA suggests an earlier
+// renewal window, it is used instead.
+func (m *Manager) renewAt(ctx context.Context, cert *tls.Certificate) (time.Time, error) {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	notAfter := leaf.NotAfter
+	for _, der := range cert.Certificate[1:] {
+		intermediate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if intermediate.NotAfter.Before(notAfter) {
+			notAfter = intermediate.NotAfter
+		}
+	}
+	policy := m.RenewalPolicy
+	if policy == nil {
+		policy = defaultRenewalPolicy
+	}
+	renewAt := policy(leaf.NotBefore, notAfter)
+	if ariRenewAt, ok := m.renewalWindow(ctx, leaf); ok && ariRenewAt.Before(renewAt) {
+		renewAt = ariRenewAt
+	}
+	return renewAt, nil
+}
+
+// ariWindow is the renewal window suggested by the CA for a certificate using
+// ACME Renewal Information (RFC 9773).
+type ariWindow struct {
+	start, end time.Time
+	// renewAt is a time randomly selected within the window, or zero if the
+	// CA did not provide any.
+	renewAt time.Time
+	// nextFetch is the time after which the window must be fetched again.
+	nextFetch time.Time
+	// failures is the number of consecutive failed fetches.
+	failures int
+}
+
+// ariKey returns the key of the renewal window of the certificate certID at
+// the i-th CA.
+func ariKey(i int, certID string) string {
+	return strconv.Itoa(i) + " " + certID
+}
+
+// renewalWindow returns the time at which leaf should be renewed according to
+// the renewal information provided by the CA that issued it. It returns false
+// if the CA does not provide any.
+func (m *Manager) renewalWindow(ctx context.Context, leaf *x509.Certificate) (time.Time, bool) {
+	w, err := m.renewalInfo(ctx, m.ariCA(ctx), leaf)
+	if err != nil && !errors.Is(err, api.ErrNoARI) && !errors.Is(err, errUnknownCertificate) {
+		m.logger.Warn("renewal info failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+	}
+	return w.renewAt, !w.renewAt.IsZero()
+}
+
+// renewalInfo returns the renewal window of leaf at the i-th CA. Windows are
+// fetched again once the retry period suggested by the CA is elapsed, and
+// failed fetches are retried with an exponential backoff, keeping the last
+// known window meanwhile. The returned error is the one of the fetch, if any
+// was needed.
+func (m *Manager) renewalInfo(ctx context.Context, i int, leaf *x509.Certificate) (ariWindow, error) {
+	certID, err := certificate.MakeARICertID(leaf)
+	if err != nil {
+		return ariWindow{}, err
+	}
+	key := ariKey(i, certID)
+	m.ariMu.Lock()
+	w, found := m.ari[key]
+	m.ariMu.Unlock()
+	now := time.Now()
+	if found && now.Before(w.nextFetch) {
+		return w, nil
+	}
+
+	info, err := m.fetchRenewalInfo(ctx, i, leaf)
+	if err == nil {
+		start, end := info.SuggestedWindow.Start, info.SuggestedWindow.End
+		if w.renewAt.IsZero() || !w.start.Equal(start) || !w.end.Equal(end) {
+			w = ariWindow{start: start, end: end, renewAt: start}
+			if window := end.Sub(start); window > 0 {
+				w.renewAt = start.Add(time.Duration(rand.Int64N(int64(window))))
+			}
+		}
+		retryAfter := info.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = defaultARIRetryAfter
+		}
+		w.failures = 0
+		w.nextFetch = now.Add(retryAfter)
+	} else {
+		w.failures++
+		w.nextFetch = now.Add(retryDelay(w.failures, defaultARIRetryAfter))
+	}
+
+	m.ariMu.Lock()
+	if m.ari == nil {
+		m.ari = map[string]ariWindow{}
+	}
+	m.ari[key] = w
+	m.ariMu.Unlock()
+	return w, err
+}
+
+// fetchRenewalInfo asks the i-th CA for the renewal information of leaf. It
+// gives up after ariTimeout, or once ctx is done or the Manager is closed.
+func (m *Manager) fetchRenewalInfo(ctx context.Context, i int, leaf *x509.Certificate) (*certificate.RenewalInfoResponse, error) {
+	client, err := m.acmeClient(i)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ariTimeout)
+	defer cancel()
+	stop := context.AfterFunc(m.ctx, cancel)
+	defer stop()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		info *certificate.RenewalInfoResponse
+		err  error
+	}
+	// The lego client does not take a context, the request is left to its own
+	// timeout once abandoned.
+	res := make(chan result, 1)
+	go func() {
+		info, err := client.Certificate.GetRenewalInfo(certificate.RenewalInfoRequest{Cert: leaf})
+		if err == nil && info.SuggestedWindow.Start.IsZero() {
+			// The CA does not know the certificate, e.g. it was issued by
+			// another CA.
+			err = errUnknownCertificate
+		}
+		res <- result{info, err}
+	}()
+	select {
+	case r := <-res:
+		return r.info, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ariCA returns the index of the CA to ask for renewal information: the CA
+// that issued the served certificate if known, the primary CA otherwise.
+func (m *Manager) ariCA(ctx context.Context) int {
+	m.resolveIssuer(ctx)
+	m.certMu.RLock()
+	defer m.certMu.RUnlock()
+	return max(m.issuer, 0)
+}
+
+// nextRenewalCheck returns the earliest time at which the current certificates
+// must be renewed or at which their renewal window must be fetched again.
+// It returns the zero time if no certificate is loaded.
+func (m *Manager) nextRenewalCheck(ctx context.Context) time.Time {
+	var next time.Time
+	for _, cert := range m.getCertificates() {
+		if renewAt, err := m.renewAt(ctx, cert); err == nil {
+			next = earliest(next, renewAt)
+		}
+	}
+	m.ariMu.Lock()
+	defer m.ariMu.Unlock()
+	for _, w := range m.ari {
+		if !w.renewAt.IsZero() {
+			// Failed fetches are retried with the next check.
+			next = earliest(next, w.nextFetch)
+		}
+	}
+	return next
+}
+
+// replacedCertIDs returns the ARI identifiers of the current certificates, in
+// key type order, if the i-th CA provides their renewal window so the new
+// order can tell the CA which certificate it replaces.
+func (m *Manager) replacedCertIDs(i int) []string {
+	m.ariMu.Lock()
+	defer m.ariMu.Unlock()
+	var ids []string
+	for _, cert := range m.getCertificates() {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil
+		}
+		id, err := certificate.MakeARICertID(leaf)
+		if err != nil {
+			return nil
+		}
+		if m.ari[ariKey(i, id)].renewAt.IsZero() {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// resetRenewalWindows forgets the renewal information of replaced
+// certificates.
+func (m *Manager) resetRenewalWindows() {
+	m.ariMu.Lock()
+	defer m.ariMu.Unlock()
+	m.ari = nil
+}
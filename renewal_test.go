@@ -0,0 +1,341 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		max      time.Duration
+		want     time.Duration
+	}{
+		{1, time.Hour, time.Minute},
+		{2, time.Hour, 2 * time.Minute},
+		{4, time.Hour, 8 * time.Minute},
+		{10, time.Hour, time.Hour},
+		{1, 30 * time.Second, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.failures, tt.max); got != tt.want {
+			t.Errorf("retryDelay(%d, %v) = %v, want %v", tt.failures, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRenewalPolicy(t *testing.T) {
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		name     string
+		policy   RenewalPolicy
+		lifetime time.Duration
+		want     time.Time
+	}{
+		{"fraction 90 days", RenewAtLifetimeFraction(2.0 / 3), 90 * day, notBefore.Add(60 * day)},
+		{"fraction 6 days", RenewAtLifetimeFraction(2.0 / 3), 6 * day, notBefore.Add(4 * day)},
+		{"before expiry", RenewBeforeExpiry(30 * day), 90 * day, notBefore.Add(60 * day)},
+		{"before expiry short-lived", RenewBeforeExpiry(30 * day), 6 * day, notBefore.Add(4 * day)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy(notBefore, notBefore.Add(tt.lifetime)); !got.Equal(tt.want) {
+				t.Errorf("policy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_renewAt(t *testing.T) {
+	day := 24 * time.Hour
+	notBefore := time.Now().Truncate(time.Second).Add(-day)
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "intermediate"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(30 * day),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		DNSNames:     []string{"example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(90 * day),
+	}, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certID, err := certificate.MakeARICertID(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: key}
+
+	// The policy applies to the validity of the chain, which ends with the
+	// intermediate after 30 days.
+	policyAt := notBefore.Add(20 * day)
+	tests := []struct {
+		name  string
+		ariAt time.Time
+		want  time.Time
+	}{
+		{"no ARI", time.Time{}, policyAt},
+		{"ARI after policy", notBefore.Add(60 * day), policyAt},
+		{"ARI before policy", notBefore.Add(2 * day), notBefore.Add(2 * day)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A window without renewAt is left by a failed fetch, as if the
+			// CA did not support ARI.
+			m := &Manager{ari: map[string]ariWindow{
+				ariKey(0, certID): {renewAt: tt.ariAt, nextFetch: time.Now().Add(time.Hour)},
+			}}
+			got, err := m.renewAt(context.Background(), cert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("renewAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_renewalInfo(t *testing.T) {
+	acme := (&testACMEServer{ari: true, retryAfter: time.Hour}).start(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	ctx := context.Background()
+	if err := m.obtain(context.Background()); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	cert := m.getCertificates()[0]
+
+	// The window suggested by the server starts in 24 hours.
+	renewAt, err := m.renewAt(ctx, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(renewAt); until < 23*time.Hour || until > 26*time.Hour {
+		t.Errorf("renewAt() in %v, want within the suggested window", until)
+	}
+	if got := acme.renewalInfoRequests(); got != 1 {
+		t.Errorf("renewal info requests = %d, want 1", got)
+	}
+	if next := time.Until(m.nextRenewalCheck(ctx)); next < 59*time.Minute || next > time.Hour {
+		t.Errorf("nextRenewalCheck() in %v, want in Retry-After", next)
+	}
+
+	// The window is reused until Retry-After is elapsed.
+	again, err := m.renewAt(ctx, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(renewAt) {
+		t.Errorf("renewAt() = %v, want %v", again, renewAt)
+	}
+	if got := acme.renewalInfoRequests(); got != 1 {
+		t.Errorf("renewal info requests = %d, want 1", got)
+	}
+	m.ariMu.Lock()
+	for key, w := range m.ari {
+		w.nextFetch = time.Now()
+		m.ari[key] = w
+	}
+	m.ariMu.Unlock()
+	if _, err := m.renewAt(ctx, cert); err != nil {
+		t.Fatal(err)
+	}
+	if got := acme.renewalInfoRequests(); got != 2 {
+		t.Errorf("renewal info requests = %d, want 2", got)
+	}
+}
+
+func TestManager_loadCache_served(t *testing.T) {
+	acme := (&testACMEServer{ari: true}).start(t)
+	m := newTestCluster(t, 1, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})[0]
+	ctx := context.Background()
+	if err := m.LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	cert := m.getCertificates()[0]
+	renewAt, err := m.renewAt(ctx, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := acme.renewalInfoRequests()
+
+	// Loading the served certificate back from the caches keeps its renewal
+	// window.
+	if err := m.loadCache(ctx); err != nil {
+		t.Fatalf("loadCache() error = %v", err)
+	}
+	again, err := m.renewAt(ctx, cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(renewAt) {
+		t.Errorf("renewAt() = %v, want %v", again, renewAt)
+	}
+	if got := acme.renewalInfoRequests(); got != requests {
+		t.Errorf("renewal info requests = %d, want %d", got, requests)
+	}
+}
+
+func TestManager_renewalInfo_unknownCertificate(t *testing.T) {
+	acme := (&testACMEServer{ari: true}).start(t)
+	m, err := New(Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := time.Now().Add(-time.Hour)
+	cert := testKeyPair(t, key, notBefore, 90*24*time.Hour)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := m.renewalInfo(ctx, 0, leaf); !errors.Is(err, errUnknownCertificate) {
+		t.Fatalf("renewalInfo() error = %v, want %v", err, errUnknownCertificate)
+	}
+	// The policy applies, and the failed lookup is not retried before its
+	// backoff.
+	for range 2 {
+		renewAt, err := m.renewAt(ctx, cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := defaultRenewalPolicy(leaf.NotBefore, leaf.NotAfter); !renewAt.Equal(want) {
+			t.Errorf("renewAt() = %v, want %v", renewAt, want)
+		}
+	}
+	if got := acme.renewalInfoRequests(); got != 1 {
+		t.Errorf("renewal info requests = %d, want 1", got)
+	}
+
+	// A cancelled context interrupts the lookup.
+	m.resetRenewalWindows()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := m.renewalInfo(cancelled, 0, leaf); !errors.Is(err, context.Canceled) {
+		t.Errorf("renewalInfo() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestManager_shortLivedProfile(t *testing.T) {
+	acme := newTestACMEServer(t)
+	cfg := Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	}
+	// The cluster serves a 90 days certificate obtained before switching to
+	// the shortlived profile.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := []*tls.Certificate{testKeyPair(t, key, time.Now().Add(-10*24*time.Hour), 90*24*time.Hour)}
+	cfg.Profile = "shortlived"
+	members := newTestCluster(t, 2, cfg)
+	ctx := context.Background()
+	for _, m := range members {
+		m.setCertificates(old, SourceCache, -1)
+		if err := m.saveCache(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := members[0].obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+	renewed := members[0].getCertificates()
+	if lifetime := leaf(renewed).NotAfter.Sub(leaf(renewed).NotBefore); lifetime != 6*24*time.Hour {
+		t.Fatalf("renewed certificate lifetime = %v, want 6 days", lifetime)
+	}
+	if err := members[0].saveCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	members[0].pushCertificates(ctx)
+	// The short-lived certificate replaces the one expiring later.
+	if !leaf(members[1].getCertificates()).Equal(leaf(renewed)) {
+		t.Fatal("pushed short-lived certificate was rejected")
+	}
+
+	// It is kept when the members reconcile with each other or with their
+	// caches.
+	for _, m := range members {
+		m.sync(ctx)
+		if err := m.loadCache(ctx); err != nil {
+			t.Fatalf("loadCache() error = %v", err)
+		}
+	}
+	for i, m := range members {
+		if !leaf(m.getCertificates()).Equal(leaf(renewed)) {
+			t.Errorf("member %d does not serve the short-lived certificate", i)
+		}
+	}
+	if orders, _ := acme.stats(); orders != 1 {
+		t.Errorf("CA got %d orders, want 1", orders)
+	}
+}
@@ -0,0 +1,101 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/rs/zerocert/internal/peer"
+	"github.com/rs/zerocert/internal/tlsutil"
+)
+
+// checkRevocation checks whether the CA revoked the served certificates, e.g.
+// during a mass revocation event, using their OCSP staple if recent enough or
+// by querying OCSP or the CRLs otherwise. It returns true if the certificates
+// were revoked, in which case they must be replaced right away.
+func (m *Manager) checkRevocation(ctx context.Context) bool {
+	certs := m.getCertificates()
+	if m.isRevoked(certs) {
+		// Already known, no need to ask again.
+		return true
+	}
+	for _, cert := range certs {
+		revoked, err := m.revokedByCA(ctx, cert)
+		if err != nil {
+			if !errors.Is(err, tlsutil.ErrNoRevocationInfo) {
+				m.logger.Warn("checking revocation status failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+			}
+			continue
+		}
+		if revoked {
+			m.handleRevocation(ctx, certs)
+			return true
+		}
+	}
+	return false
+}
+
+// revokedByCA returns the revocation status of cert.
+func (m *Manager) revokedByCA(ctx context.Context, cert *tls.Certificate) (bool, error) {
+	if resp, err := tlsutil.ParseOCSPStaple(cert); err == nil && resp != nil &&
+		time.Since(resp.ThisUpdate) < m.checkInterval() {
+		return resp.Status == ocsp.Revoked, nil
+	}
+	return m.revocations.Check(ctx, cert)
+}
+
+// handleRevocation marks certs as revoked by the CA so they need a refresh and
+// are never loaded back from the caches or the other members of the cluster,
+// and tells the other members to do the same. Unlike with Revoke, they are
+// still served until replaced, as CAs are often slow to issue new
+// certificates during a mass revocation, and a restart serves them again
+// until the revocation is checked.
+func (m *Manager) handleRevocation(ctx context.Context, certs []*tls.Certificate) {
+	m.logger.Error("served certificate revoked by the CA, replacing it", slog.String("domain", m.names[0]))
+	fingerprints := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		fingerprints = append(fingerprints, peer.Fingerprint(cert.Certificate[0]))
+	}
+	m.markRevokedByCA(fingerprints)
+	if m.cache.Caches == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	if err := m.peers.Revoked(ctx, fingerprints); err != nil {
+		m.logger.Warn("telling peers about the revoked certificate failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+	}
+}
+
+// markRevokedByCA marks the certificates with the given fingerprints as
+// revoked by the CA and returns true if the served certificates are among
+// them.
+func (m *Manager) markRevokedByCA(fingerprints []string) bool {
+	m.certMu.Lock()
+	defer m.certMu.Unlock()
+	if m.caRevoked == nil {
+		m.caRevoked = make(map[string]bool)
+	}
+	for _, fp := range fingerprints {
+		m.caRevoked[fp] = true
+	}
+	return hasFingerprint(m.certs, m.caRevoked)
+}
+
+// revokedFromPeer marks the certificates another member of the cluster found
+// revoked by the CA. If the served certificates are among them, Run is
+// signaled to replace them right away.
+func (m *Manager) revokedFromPeer(fingerprints []string) {
+	if !m.markRevokedByCA(fingerprints) {
+		return
+	}
+	m.logger.Warn("served certificate revoked by the CA according to a peer", slog.String("domain", m.names[0]))
+	select {
+	case m.renewNow <- struct{}{}:
+	default:
+	}
+}
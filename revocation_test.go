@@ -0,0 +1,67 @@
+package zerocert
+
+import (
+	"context"
+	"testing"
+)
+
+func TestManager_LoadOrRefresh_revokedByCA(t *testing.T) {
+	acme := (&testACMEServer{ocsp: true}).start(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	ctx := context.Background()
+	for _, m := range members {
+		if err := m.LoadOrRefresh(ctx); err != nil {
+			t.Fatalf("LoadOrRefresh() error = %v", err)
+		}
+	}
+	old := members[0].getCertificates()
+
+	// The CA revokes the certificate and fails to issue a new one: the
+	// member noticing it keeps serving the revoked certificate and tells the
+	// other member, which does the same.
+	acme.revokeOCSP(leaf(old))
+	acme.setFailOrders(true)
+	if err := members[0].LoadOrRefresh(ctx); err == nil {
+		t.Error("LoadOrRefresh() error = nil, want the order error")
+	}
+	for i, m := range members {
+		if !leaf(m.getCertificates()).Equal(leaf(old)) {
+			t.Errorf("member %d stopped serving the certificate revoked by the CA", i)
+		}
+		if !m.needsRefresh(ctx) {
+			t.Errorf("member %d needsRefresh() = false, want true", i)
+		}
+	}
+	select {
+	case <-members[1].renewNow:
+	default:
+		t.Error("the other member did not signal a renewal check")
+	}
+
+	// A restarted member serves it again until it checks its status.
+	restarted, err := New(members[0].Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Close()
+	if restarted.isRevoked(old) {
+		t.Error("certificate revoked by the CA remembered after a restart")
+	}
+
+	// Once the CA issues certificates again, the other member replaces the
+	// certificate and pushes the new one.
+	acme.setFailOrders(false)
+	if err := members[1].LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	for i, m := range members {
+		if certs := m.getCertificates(); len(certs) == 0 || leaf(certs).Equal(leaf(old)) {
+			t.Errorf("member %d does not serve a new certificate", i)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package zerocert
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-acme/lego/v4/acme"
+
+	"github.com/rs/zerocert/internal/peer"
+)
+
+// RevocationReason is the reason code of a revocation as defined by RFC 5280.
+type RevocationReason uint
+
+// Revocation reasons accepted by most CAs.
+const (
+	ReasonUnspecified          = RevocationReason(acme.CRLReasonUnspecified)
+	ReasonKeyCompromise        = RevocationReason(acme.CRLReasonKeyCompromise)
+	ReasonAffiliationChanged   = RevocationReason(acme.CRLReasonAffiliationChanged)
+	ReasonSuperseded           = RevocationReason(acme.CRLReasonSuperseded)
+	ReasonCessationOfOperation = RevocationReason(acme.CRLReasonCessationOfOperation)
+)
+
+// Revoke revokes the served certificates at the CA that issued them, tells
+// every member of the cluster to stop serving them and obtains new ones with
+// new keys. Revoked certificates are never loaded back from the caches, even
+// after a restart. If revoking one of the certificates fails, the ones
+// already revoked are still replaced.
+//
+// If the issuer can't be found, e.g. when none of the CAs supports ARI, each
+// configured CA is tried in order.
+func (m *Manager) Revoke(ctx context.Context, reason RevocationReason) error {
+	if err := m.initialize(); err != nil {
+		return err
+	}
+	m.resolveIssuer(ctx)
+	m.certMu.RLock()
+	certs, issuer := m.certs, m.issuer
+	m.certMu.RUnlock()
+	if len(certs) == 0 {
+		return errors.New("no certificate to revoke")
+	}
+
+	var revoked []*tls.Certificate
+	var revokeErr error
+	for _, cert := range certs {
+		if err := m.revoke(cert, issuer, reason); err != nil {
+			revokeErr = fmt.Errorf("revoke: %w", err)
+			break
+		}
+		revoked = append(revoked, cert)
+	}
+	if len(revoked) == 0 {
+		return revokeErr
+	}
+
+	// Certificates revoked before a failure must not be served either.
+	m.logger.Info("certificate revoked", slog.String("domain", m.names[0]), slog.String("reason", fmt.Sprint(uint(reason))))
+	if err := m.purgeCluster(ctx, revoked); err != nil {
+		return errors.Join(revokeErr, fmt.Errorf("purge: %w", err))
+	}
+
+	return errors.Join(revokeErr, m.LoadOrRefresh(ctx))
+}
+
+// purgeCluster stops serving the revoked certs and tells the other members of
+// the cluster to do the same. Failing to reach them is only logged.
+func (m *Manager) purgeCluster(ctx context.Context, certs []*tls.Certificate) error {
+	fingerprints := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		fingerprints = append(fingerprints, peer.Fingerprint(cert.Certificate[0]))
+	}
+	if _, err := m.purge(ctx, fingerprints); err != nil {
+		return err
+	}
+	if m.cache.Caches != nil {
+		ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+		defer cancel()
+		if err := m.peers.Purge(ctx, fingerprints); err != nil {
+			m.logger.Warn("purging revoked certificate from peers failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// revoke revokes cert at the i-th CA, or at each CA in order until one
+// succeeds if i is negative.
+func (m *Manager) revoke(cert *tls.Certificate, i int, reason RevocationReason) error {
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	r := uint(reason)
+	var errs []error
+	for j, ca := range m.acmeCAs {
+		if i >= 0 && j != i {
+			continue
+		}
+		client, err := m.accountClient(j)
+		if err == nil {
+			err = client.Certificate.RevokeWithReason(b, &r)
+		}
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ca.directory(), err))
+	}
+	return errors.Join(errs...)
+}
+
+// purge stops serving the certificates with the given fingerprints, removes
+// them from the local cache and prevents them from being loaded again. The
+// fingerprints are stored in the revoked file to survive restarts. It returns
+// true if the served certificates were dropped.
+func (m *Manager) purge(ctx context.Context, fingerprints []string) (bool, error) {
+	m.certMu.Lock()
+	if m.revoked == nil {
+		m.revoked = make(map[string]bool)
+	}
+	var added bool
+	for _, fp := range fingerprints {
+		added = added || !m.revoked[fp]
+		m.revoked[fp] = true
+	}
+	if added {
+		if err := m.saveRevokedLocked(); err != nil {
+			m.logger.Warn("saving revoked certificates failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+		}
+	}
+	served := hasFingerprint(m.certs, m.revoked)
+	if served {
+		m.certs = nil
+		m.issuer = -1
+	}
+	m.certMu.Unlock()
+	if !served {
+		return false, nil
+	}
+
+	m.logger.Warn("stopped serving revoked certificate", slog.String("domain", m.names[0]))
+	m.resetRenewalWindows()
+	if m.cache.Caches == nil {
+		return true, nil
+	}
+	certs, err := m.fileCache.Get(ctx)
+	if err != nil || m.isRevoked(certs) {
+		// Remove unreadable files too as they can't be checked.
+		return true, m.fileCache.Delete(ctx)
+	}
+	return true, nil
+}
+
+// purgeFromPeer purges the certificates revoked by another member of the
+// cluster. If the served certificates are dropped, Run is signaled to replace
+// them right away in case that member fails to push their replacement.
+func (m *Manager) purgeFromPeer(ctx context.Context, fingerprints []string) error {
+	served, err := m.purge(ctx, fingerprints)
+	if served {
+		select {
+		case m.renewNow <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// isRevoked returns true if any of certs has been revoked, with Revoke or by
+// the CA.
+func (m *Manager) isRevoked(certs []*tls.Certificate) bool {
+	m.certMu.RLock()
+	defer m.certMu.RUnlock()
+	return hasFingerprint(certs, m.revoked) || hasFingerprint(certs, m.caRevoked)
+}
+
+// hasFingerprint returns true if the fingerprint of any of certs is in
+// fingerprints.
+func hasFingerprint(certs []*tls.Certificate, fingerprints map[string]bool) bool {
+	for _, cert := range certs {
+		if fingerprints[peer.Fingerprint(cert.Certificate[0])] {
+			return true
+		}
+	}
+	return false
+}
+
+// revokedFile returns the file storing the fingerprints of the revoked
+// certificates, next to the cache file, or an empty string without cache
+// file.
+func (m *Manager) revokedFile() string {
+	if m.CacheFile == "" {
+		return ""
+	}
+	return m.CacheFile + ".revoked"
+}
+
+// loadRevoked loads the fingerprints of the certificates revoked before a
+// restart.
+func (m *Manager) loadRevoked() error {
+	file := m.revokedFile()
+	if file == "" {
+		return nil
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return err
+	}
+	m.certMu.Lock()
+	defer m.certMu.Unlock()
+	if m.revoked == nil {
+		m.revoked = make(map[string]bool)
+	}
+	for _, fp := range strings.Fields(string(b)) {
+		m.revoked[fp] = true
+	}
+	return nil
+}
+
+// saveRevokedLocked stores the fingerprints of the revoked certificates, one
+// per line. The caller must hold certMu.
+func (m *Manager) saveRevokedLocked() error {
+	file := m.revokedFile()
+	if file == "" {
+		return nil
+	}
+	fps := make([]string, 0, len(m.revoked))
+	for fp := range m.revoked {
+		fps = append(fps, fp+"\n")
+	}
+	slices.Sort(fps)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(strings.Join(fps, "")), 0600)
+}
@@ -0,0 +1,135 @@
+package zerocert
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManager_Revoke(t *testing.T) {
+	acme := newTestACMEServer(t)
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})
+	ctx := context.Background()
+	for _, m := range members {
+		if err := m.LoadOrRefresh(ctx); err != nil {
+			t.Fatalf("LoadOrRefresh() error = %v", err)
+		}
+	}
+	old := members[0].getCertificates()
+	if !leaf(members[1].getCertificates()).Equal(leaf(old)) {
+		t.Fatal("members do not serve the same certificate")
+	}
+
+	if err := members[0].Revoke(ctx, ReasonKeyCompromise); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if orders, revoked := acme.stats(); orders != 2 || revoked != 1 {
+		t.Errorf("CA got %d orders and %d revocations, want 2 and 1", orders, revoked)
+	}
+	for i, m := range members {
+		if !m.isRevoked(old) {
+			t.Errorf("member %d did not purge the revoked certificate", i)
+		}
+		// The other member's Run is told to check the certificate right away
+		// in case the new one failed to be pushed. The revoking member
+		// replaces it on its own.
+		select {
+		case <-m.renewNow:
+			if i == 0 {
+				t.Error("the revoking member signaled a renewal check")
+			}
+		default:
+			if i != 0 {
+				t.Errorf("member %d did not signal a renewal check", i)
+			}
+		}
+		if certs := m.getCertificates(); len(certs) == 0 || leaf(certs).Equal(leaf(old)) {
+			t.Errorf("member %d does not serve a new certificate", i)
+		}
+		cached, err := m.fileCache.Get(ctx)
+		if err != nil || len(cached) == 0 || leaf(cached).Equal(leaf(old)) {
+			t.Errorf("member %d cache does not hold a new certificate: %v", i, err)
+		}
+
+		// The revoked certificate is not loaded back after a restart.
+		restarted, err := New(m.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		restarted.Close()
+		if !restarted.isRevoked(old) {
+			t.Errorf("member %d forgot the revoked certificate after a restart", i)
+		}
+	}
+}
+
+func TestManager_Revoke_partial(t *testing.T) {
+	acme := (&testACMEServer{maxRevocations: 1}).start(t)
+	m := newTestCluster(t, 1, Config{
+		Key:             []byte(testKey),
+		Domain:          "example.com",
+		DirectoryURL:    acme.directory(),
+		CARoots:         acme.roots,
+		KeyType:         EC256,
+		FallbackKeyType: RSA2048,
+	})[0]
+	ctx := context.Background()
+	if err := m.LoadOrRefresh(ctx); err != nil {
+		t.Fatalf("LoadOrRefresh() error = %v", err)
+	}
+	old := m.getCertificates()
+
+	if err := m.Revoke(ctx, ReasonKeyCompromise); err == nil {
+		t.Fatal("Revoke() error = nil, want the error of the second revocation")
+	}
+	// The certificate revoked before the failure is replaced anyway.
+	if !m.isRevoked(old[:1]) {
+		t.Error("the revoked certificate was not purged")
+	}
+	if m.isRevoked(old[1:]) {
+		t.Error("the certificate failing to be revoked was purged")
+	}
+	certs := m.getCertificates()
+	if len(certs) != 2 || leaf(certs).Equal(leaf(old)) {
+		t.Error("the revoked certificate was not replaced")
+	}
+	cached, err := m.fileCache.Get(ctx)
+	if err != nil || len(cached) == 0 || leaf(cached).Equal(leaf(old)) {
+		t.Errorf("cache does not hold the new certificates: %v", err)
+	}
+	if orders, revoked := acme.stats(); orders != 4 || revoked != 1 {
+		t.Errorf("CA got %d orders and %d revocations, want 4 and 1", orders, revoked)
+	}
+}
+
+func TestManager_Revoke_running(t *testing.T) {
+	acme := newTestACMEServer(t)
+	m := newTestCluster(t, 1, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+	})[0]
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	for deadline := time.Now().Add(5 * time.Second); m.GetCertificate() == nil; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Run() did not obtain a certificate")
+		}
+	}
+
+	// The replacement is ordered once, by Revoke and not by Run.
+	if err := m.Revoke(ctx, ReasonKeyCompromise); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if orders, revoked := acme.stats(); orders != 2 || revoked != 1 {
+		t.Errorf("CA got %d orders and %d revocations, want 2 and 1", orders, revoked)
+	}
+}
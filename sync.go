@@ -0,0 +1,126 @@
+package zerocert
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerocert/internal/peer"
+)
+
+// defaultSyncInterval is the default interval between two reconciliations of
+// the certificates with the other members of the cluster.
+const defaultSyncInterval = time.Hour
+
+// syncLoop reconciles the certificates with the other members of the cluster
+// at every SyncInterval until ctx is done.
+func (m *Manager) syncLoop(ctx context.Context) {
+	select {
+	case <-m.tlsListenerStarted:
+	case <-ctx.Done():
+		return
+	}
+	interval := m.SyncInterval
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitter(interval)):
+		}
+		m.sync(ctx)
+	}
+}
+
+// sync compares the served certificates with the ones of every member of the
+// cluster and fetches the key pairs of the member serving the most recently
+// issued certificate if it was issued after the served one. Members serving
+// different certificates are logged.
+func (m *Manager) sync(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+
+	ips, err := m.peers.IPs(ctx)
+	if err != nil {
+		m.logger.Warn("sync: listing peers failed", slog.String("domain", m.names[0]), slog.Any("error", err))
+		return
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		remotes = make(map[string][]peer.CertInfo, len(ips))
+	)
+	for _, ip := range ips {
+		wg.Add(1)
+		go func(ip net.IP) {
+			defer wg.Done()
+			infos, err := m.peers.Info(ctx, ip)
+			if err != nil {
+				m.logger.Debug("sync: peer unreachable", slog.String("peer", ip.String()), slog.Any("error", err))
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			remotes[ip.String()] = infos
+		}(ip)
+	}
+	wg.Wait()
+
+	newest, diverging := newestPeer(peer.NewCertInfos(m.getCertificates()), remotes)
+	if len(diverging) == 0 {
+		return
+	}
+	m.logger.Info("sync: peers serving a different certificate", slog.String("domain", m.names[0]), slog.Any("peers", diverging))
+	if newest == "" {
+		return
+	}
+
+	start := time.Now()
+	certs, err := m.peers.Fetch(ctx, net.ParseIP(newest))
+	m.metrics.PeerFetch(newest, time.Since(start), err)
+	if err == nil {
+		err = m.acceptPushed(ctx, certs)
+	}
+	if err != nil {
+		m.logger.Warn("sync: fetching certificate from peer failed", slog.String("peer", newest), slog.Any("error", err))
+	}
+}
+
+// newestPeer returns the sorted list of the peers of remotes serving other
+// certificates than local and, among them, the peer serving the most recently
+// issued certificate, if issued after the local one. Certificates are ordered
+// by the NotBefore date of their first key pair like in acceptPushed, as a
+// renewed certificate may expire before the one it replaces.
+func newestPeer(local []peer.CertInfo, remotes map[string][]peer.CertInfo) (newest string, diverging []string) {
+	var newestStart time.Time
+	if len(local) > 0 {
+		newestStart = local[0].NotBefore
+	}
+	for ip, infos := range remotes {
+		if !slices.Equal(fingerprints(infos), fingerprints(local)) {
+			diverging = append(diverging, ip)
+		}
+	}
+	slices.Sort(diverging)
+	for _, ip := range diverging {
+		if infos := remotes[ip]; len(infos) > 0 && infos[0].NotBefore.After(newestStart) {
+			newest, newestStart = ip, infos[0].NotBefore
+		}
+	}
+	return newest, diverging
+}
+
+// fingerprints returns the fingerprints of infos.
+func fingerprints(infos []peer.CertInfo) []string {
+	fps := make([]string, 0, len(infos))
+	for _, info := range infos {
+		fps = append(fps, info.Fingerprint)
+	}
+	return fps
+}
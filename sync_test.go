@@ -0,0 +1,121 @@
+package zerocert
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerocert/internal/peer"
+)
+
+func TestNewestPeer(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now()
+	info := func(fp string, issued time.Time, lifetime time.Duration) peer.CertInfo {
+		return peer.CertInfo{Fingerprint: fp, NotBefore: issued, NotAfter: issued.Add(lifetime)}
+	}
+	local := []peer.CertInfo{info("local", now.Add(-30*day), 90*day)}
+	older := []peer.CertInfo{info("older", now.Add(-40*day), 100*day)}
+	renewed := []peer.CertInfo{info("renewed", now.Add(-day), 90*day)}
+	shortLived := []peer.CertInfo{info("short-lived", now.Add(-time.Hour), 6*day)}
+
+	tests := []struct {
+		name          string
+		local         []peer.CertInfo
+		remotes       map[string][]peer.CertInfo
+		wantNewest    string
+		wantDiverging []string
+	}{
+		{
+			name:    "in sync",
+			local:   local,
+			remotes: map[string][]peer.CertInfo{"10.0.0.1": local, "10.0.0.2": local},
+		},
+		{
+			name:          "renewed",
+			local:         local,
+			remotes:       map[string][]peer.CertInfo{"10.0.0.1": local, "10.0.0.2": renewed},
+			wantNewest:    "10.0.0.2",
+			wantDiverging: []string{"10.0.0.2"},
+		},
+		{
+			name:          "older with later expiry",
+			local:         local,
+			remotes:       map[string][]peer.CertInfo{"10.0.0.1": older},
+			wantDiverging: []string{"10.0.0.1"},
+		},
+		{
+			name:          "shorter lifetime",
+			local:         local,
+			remotes:       map[string][]peer.CertInfo{"10.0.0.1": older, "10.0.0.2": renewed, "10.0.0.3": shortLived},
+			wantNewest:    "10.0.0.3",
+			wantDiverging: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		},
+		{
+			name:          "no local certificate",
+			remotes:       map[string][]peer.CertInfo{"10.0.0.1": older, "10.0.0.2": nil},
+			wantNewest:    "10.0.0.1",
+			wantDiverging: []string{"10.0.0.1"},
+		},
+		{
+			name:          "other key type differs",
+			local:         []peer.CertInfo{local[0], info("rsa", now.Add(-30*day), 90*day)},
+			remotes:       map[string][]peer.CertInfo{"10.0.0.1": {local[0], info("other rsa", now.Add(-30*day), 90*day)}},
+			wantDiverging: []string{"10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newest, diverging := newestPeer(tt.local, tt.remotes)
+			if newest != tt.wantNewest {
+				t.Errorf("newestPeer() newest = %q, want %q", newest, tt.wantNewest)
+			}
+			if !slices.Equal(diverging, tt.wantDiverging) {
+				t.Errorf("newestPeer() diverging = %v, want %v", diverging, tt.wantDiverging)
+			}
+		})
+	}
+}
+
+// peerFetchMetrics records the peers reported by PeerFetch.
+type peerFetchMetrics struct {
+	nopMetrics
+	mu    sync.Mutex
+	peers []string
+}
+
+func (m *peerFetchMetrics) PeerFetch(peer string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		m.peers = append(m.peers, peer)
+	}
+}
+
+func TestManager_sync_peerFetchMetric(t *testing.T) {
+	acme := newTestACMEServer(t)
+	metrics := &peerFetchMetrics{}
+	members := newTestCluster(t, 2, Config{
+		Key:          []byte(testKey),
+		Domain:       "example.com",
+		DirectoryURL: acme.directory(),
+		CARoots:      acme.roots,
+		Metrics:      metrics,
+	})
+	ctx := context.Background()
+	if err := members[0].obtain(ctx); err != nil {
+		t.Fatalf("obtain() error = %v", err)
+	}
+
+	members[1].sync(ctx)
+	if !leaf(members[1].getCertificates()).Equal(leaf(members[0].getCertificates())) {
+		t.Fatal("sync() did not fetch the certificate of the peer")
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if want := []string{"127.0.0.1"}; !slices.Equal(metrics.peers, want) {
+		t.Errorf("PeerFetch peers = %q, want %q", metrics.peers, want)
+	}
+}